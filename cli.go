@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const cliSubmitterIP = "cli"
+
+// cliCommand is an operations subcommand run from the command line.
+type cliCommand struct {
+	Usage       string
+	Description string
+	// NoConfig commands run without loading the config and data mappings
+	NoConfig bool
+	Run      func(config *Config, args []string) error
+}
+
+// exportCharacter is a single line of the export output.
+type exportCharacter struct {
+	Character    Character              `json:"character"`
+	Progressions []CharacterProgression `json:"progressions"`
+	Aliases      []CharacterAlias       `json:"aliases"`
+}
+
+var errCLIUsage = errors.New("invalid arguments")
+
+var cliCommands = map[string]cliCommand{
+	"serve": {
+		Usage:       "serve",
+		Description: "start the web server, import queue and report discovery (default)",
+		Run:         cliServe,
+	},
+	"import": {
+		Usage:       "import <report-url...>",
+		Description: "import FFLogs reports immediately, skipping reports that were already imported",
+		Run: func(config *Config, args []string) error {
+			return cliImport(config, args, false)
+		},
+	},
+	"reimport": {
+		Usage:       "reimport <report-url...>",
+		Description: "import FFLogs reports immediately, even if they were already imported",
+		Run: func(config *Config, args []string) error {
+			return cliImport(config, args, true)
+		},
+	},
+	"export": {
+		Usage:       "export [file]",
+		Description: "write every visible character and their progression as json lines to a file or stdout",
+		Run:         cliExport,
+	},
+	"migrate": {
+		Usage:       "migrate",
+		Description: "create or update the database tables and backfill data",
+		Run:         cliMigrate,
+	},
+	"stats": {
+		Usage:       "stats",
+		Description: "print database totals and clears per encounter",
+		Run:         cliStats,
+	},
+	"character": {
+		Usage:       "character show <uid|name@server>",
+		Description: "print a character's details and best progression",
+		Run:         cliCharacter,
+	},
+	"delete-report": {
+		Usage:       "delete-report <report-url...>",
+		Description: "delete reports and everything imported from them, rebuilding the progressions they overshadowed (requires store_pulls)",
+		Run:         cliDeleteReport,
+	},
+	"fake-fflogs": {
+		Usage:       "fake-fflogs <address>",
+		Description: "serve FFLogs report fixtures through the v1 and v2 apis on the given address for offline testing",
+		NoConfig:    true,
+		Run:         cliFakeFFLogs,
+	},
+}
+
+func printCLIUsage(w io.Writer) {
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Usage: %s <command> [arguments]\n\nCommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", cliCommands[name].Usage, cliCommands[name].Description)
+	}
+	tw.Flush()
+}
+
+// RunCLI runs the subcommand named by the first argument, the web server is started when there is none.
+func RunCLI(args []string) error {
+	name := "serve"
+	if len(args) > 0 {
+		name = args[0]
+		args = args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		printCLIUsage(os.Stdout)
+		return nil
+	}
+	command, ok := cliCommands[name]
+	if !ok {
+		printCLIUsage(os.Stderr)
+		return fmt.Errorf("unknown command %s", name)
+	}
+	var config *Config
+	if !command.NoConfig {
+		// load global config
+		log.Println("Load config JSON.")
+		loadedConfig, err := LoadConfig()
+		if err != nil {
+			return err
+		}
+		config = &loadedConfig
+
+		// fetch mappings data
+		log.Println("Load data mappings.")
+		if err := LoadDataMaps(); err != nil {
+			return err
+		}
+	}
+	err := command.Run(config, args)
+	if err == errCLIUsage {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], command.Usage)
+	}
+	return err
+}
+
+func cliServe(config *Config, args []string) error {
+	// reload data mappings on sighup so new worlds don't require a restart
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for range sighup {
+			log.Println("Reload data mappings.")
+			if err := LoadDataMaps(); err != nil {
+				log.Printf("Error reloading data mappings: %s\n", err.Error())
+			}
+		}
+	}()
+
+	// start web server
+	log.Println("Start web server.")
+	return StartWeb(config)
+}
+
+// cliImport fetches and saves each report in turn, recording an import job the same way the queue does.
+func cliImport(config *Config, args []string, force bool) error {
+	if len(args) == 0 {
+		return errCLIUsage
+	}
+	db, err := NewDatabaserHandler(config)
+	if err != nil {
+		return err
+	}
+	fflogHandler, err := NewFFLogsHandler(config)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, arg := range args {
+		reportID := FFLogReportURLToReportID(arg)
+		if reportID == "" {
+			log.Printf("Invalid FFLogs report %s.\n", arg)
+			failed++
+			continue
+		}
+		if !force && db.HasFFLogsReport(reportID) {
+			log.Printf("FFLogs report %s has already been processed, use reimport to process it again.\n", reportID)
+			continue
+		}
+		importJob, err := db.FetchImportJobFromReportID(reportID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if importJob.IsPending() {
+			log.Printf("FFLogs report %s is in the import queue, skipping.\n", reportID)
+			continue
+		}
+		importJob.ReportID = reportID
+		importJob.Status = ImportStatusFetching
+		importJob.Attempts = 1
+		importJob.SubmitterIP = cliSubmitterIP
+		importJob.EnqueuedAt = time.Now()
+		importJob.NextAttemptAt = importJob.EnqueuedAt
+		importJob.LastError = ""
+		importJob.PermanentFailure = false
+		if err := db.SaveImportJob(&importJob); err != nil {
+			return err
+		}
+		log.Printf("Processing FFLogs report %s.\n", reportID)
+		report, err := fetchAndSaveReport(db, fflogHandler, reportID)
+		if err != nil {
+			log.Printf("Error importing FFLogs report %s: %s\n", reportID, err.Error())
+			failed++
+			importJob.Status = ImportStatusFailed
+			importJob.LastError = importErrorMessage(err)
+			importJob.PermanentFailure = !isImportErrorTransient(err)
+		} else {
+			log.Printf("Imported FFLogs report %s, %d character(s) and %d progression(s) (%s).\n", reportID, report.CharacterCount, report.ProgressionCount, report.Outcome)
+			importJob.Status = ImportStatusDone
+			importJob.CharacterCount = report.CharacterCount
+			importJob.ProgressionCount = report.ProgressionCount
+		}
+		if err := db.SaveImportJob(&importJob); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d report(s) failed to import", failed)
+	}
+	return nil
+}
+
+func fetchAndSaveReport(db *DatabaseHandler, fflogHandler *FFLogsHandler, reportID string) (Report, error) {
+	fflReport, err := fflogHandler.FetchReport(reportID)
+	if err != nil {
+		return Report{}, err
+	}
+	return db.HandleFFLogReport(fflReport)
+}
+
+func cliExport(config *Config, args []string) error {
+	if len(args) > 1 {
+		return errCLIUsage
+	}
+	db, err := NewDatabaserHandler(config)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	count := 0
+	if err := db.EachVisibleCharacter(func(character Character) error {
+		out := exportCharacter{Character: character}
+		var err error
+		if out.Progressions, err = db.FetchBestCharacterProgressions(character.ID); err != nil {
+			return err
+		}
+		if out.Aliases, err = db.FetchCharacterAliases(character.ID); err != nil {
+			return err
+		}
+		count++
+		return encoder.Encode(out)
+	}); err != nil {
+		return err
+	}
+	log.Printf("Exported %d character(s).\n", count)
+	return nil
+}
+
+func cliMigrate(config *Config, args []string) error {
+	// tables are migrated whenever the database is opened
+	if _, err := NewDatabaserHandler(config); err != nil {
+		return err
+	}
+	log.Println("Database migrated.")
+	return nil
+}
+
+func cliStats(config *Config, args []string) error {
+	db, err := NewDatabaserHandler(config)
+	if err != nil {
+		return err
+	}
+	stats, err := db.FetchDatabaseStats()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Characters\t%d\n", stats.Characters)
+	fmt.Fprintf(tw, "Hidden characters\t%d\n", stats.HiddenCharacters)
+	fmt.Fprintf(tw, "Merged characters\t%d\n", stats.MergedCharacters)
+	fmt.Fprintf(tw, "Reports\t%d\n", stats.Reports)
+	fmt.Fprintf(tw, "Progressions\t%d\n", stats.Progressions)
+	fmt.Fprintf(tw, "Pulls\t%d\n", stats.Pulls)
+	fmt.Fprintf(tw, "Statics\t%d\n", stats.Statics)
+	fmt.Fprintf(tw, "Pending imports\t%d\n", stats.PendingImports)
+	fmt.Fprintf(tw, "Failed imports\t%d\n", stats.FailedImports)
+	encounterList, err := db.FetchEncounterList()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Encounter\tCharacters\tClears")
+	for _, encounter := range encounterList {
+		distribution, err := db.FetchEncounterDistribution(encounter.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", encounter.Name(), distribution.Characters, distribution.Clears)
+	}
+	return tw.Flush()
+}
+
+func cliCharacter(config *Config, args []string) error {
+	if len(args) != 2 || args[0] != "show" {
+		return errCLIUsage
+	}
+	db, err := NewDatabaserHandler(config)
+	if err != nil {
+		return err
+	}
+	var character Character
+	if name, server, ok := strings.Cut(args[1], "@"); ok {
+		character, err = db.FetchCharacterFromNameServer(strings.TrimSpace(name), strings.TrimSpace(server))
+	} else {
+		character, err = db.FetchCharacterFromUID(strings.ToLower(strings.TrimSpace(args[1])))
+	}
+	if err == nil {
+		character, err = db.FetchCanonicalCharacter(character)
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("character %s not found", args[1])
+		}
+		return err
+	}
+	characterProgressions, err := db.FetchBestCharacterProgressions(character.ID)
+	if err != nil {
+		return err
+	}
+	characterAliases, err := db.FetchCharacterAliases(character.ID)
+	if err != nil {
+		return err
+	}
+	statics, err := db.FetchStaticsForCharacter(character.ID)
+	if err != nil {
+		return err
+	}
+	_, claimErr := db.FetchVerifiedCharacterClaim(character.ID)
+	if claimErr != nil && claimErr != gorm.ErrRecordNotFound {
+		return claimErr
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name\t%s\n", character.Name)
+	fmt.Fprintf(tw, "Server\t%s (%s, %s)\n", character.Server, GetServerDataCenter(character.Server), GetServerRegion(character.Server))
+	fmt.Fprintf(tw, "ID\t%s\n", character.UID)
+	fmt.Fprintf(tw, "FFLogs ID\t%d\n", character.GameID)
+	fmt.Fprintf(tw, "Verified\t%t\n", claimErr == nil)
+	fmt.Fprintf(tw, "Hidden\t%t\n", character.Hidden)
+	for _, characterAlias := range characterAliases {
+		fmt.Fprintf(tw, "Seen as\t%s (%s), last %s\n", characterAlias.Name, characterAlias.Server, characterAlias.LastSeen.Format("2006-01-02"))
+	}
+	for _, static := range statics {
+		fmt.Fprintf(tw, "Static\t%s (%s)\n", static.DisplayName(), static.UID)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Encounter\tJob\tProgression\tDate\tReport")
+	for _, characterProgression := range characterProgressions {
+		progression := fmt.Sprintf("%.2f%%", float32(characterProgression.FightPercentage)/100.0)
+		if characterProgression.IsKill {
+			progression = "cleared"
+		} else if characterProgression.Phase > 0 {
+			progression = fmt.Sprintf("P%d %.2f%%", characterProgression.Phase, float32(characterProgression.PhasePercentage)/100.0)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			characterProgression.EncounterInfo.Name(),
+			strings.ToUpper(characterProgression.Job),
+			progression,
+			characterProgression.Time.Format("2006-01-02"),
+			characterProgression.ReportID,
+		)
+	}
+	return tw.Flush()
+}
+
+func cliDeleteReport(config *Config, args []string) error {
+	if len(args) == 0 {
+		return errCLIUsage
+	}
+	db, err := NewDatabaserHandler(config)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		reportID := FFLogReportURLToReportID(arg)
+		if reportID == "" {
+			return fmt.Errorf("invalid fflogs report %s", arg)
+		}
+		// remove a bad report and roll back the progressions it overshadowed
+		if err := db.DeleteReport(reportID); err == ErrProgressionsNotRebuilt {
+			log.Printf("Deleted FFLogs report %s, warning: %s.\n", reportID, err.Error())
+			continue
+		} else if err != nil {
+			return err
+		}
+		log.Printf("Deleted FFLogs report %s.\n", reportID)
+	}
+	return nil
+}
+
+func cliFakeFFLogs(config *Config, args []string) error {
+	if len(args) != 1 {
+		return errCLIUsage
+	}
+	// start stand in fflogs server for offline testing
+	log.Printf("Start fake FFLogs server on %s.\n", args[0])
+	return http.ListenAndServe(args[0], NewFakeFFLogsHandler(fakeFFLogsFixtureDir))
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"strings"
+	"time"
 
 	"muzzammil.xyz/jsonc"
 )
@@ -15,11 +17,60 @@ type DisplayEncounterCategory struct {
 	BossIDs []int  `json:"boss_ids"`
 }
 
+// DiscoveryGuild is an FFLogs guild whose reports are imported automatically.
+type DiscoveryGuild struct {
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	Region string `json:"region"`
+}
+
 type Config struct {
-	FFLogsApiKey        string                     `json:"fflogs_api_key"`
-	DatabaseFile        string                     `json:"database_file"`
-	DisplayedEncounters []DisplayEncounterCategory `json:"displayed_encounters"`
-	HTTPPort            int                        `json:"http_port"`
+	FFLogsAPIVersion     string                     `json:"fflogs_api_version"`
+	FFLogsApiKey         string                     `json:"fflogs_api_key"`
+	FFLogsClientID       string                     `json:"fflogs_client_id"`
+	FFLogsClientSecret   string                     `json:"fflogs_client_secret"`
+	FFLogsBaseURL        string                     `json:"fflogs_base_url"`
+	DatabaseFile         string                     `json:"database_file"`
+	DisplayedEncounters  []DisplayEncounterCategory `json:"displayed_encounters"`
+	HTTPPort             int                        `json:"http_port"`
+	ImportMaxAttempts    int                        `json:"import_max_attempts"`
+	ImportWorkers        int                        `json:"import_workers"`
+	FFLogsRateLimit      float64                    `json:"fflogs_requests_per_minute"`
+	ReportLiveWindow     int                        `json:"report_live_window"`
+	ReportRefreshDelay   int                        `json:"report_refresh_cooldown"`
+	AdminToken           string                     `json:"admin_token"`
+	AdminUsername        string                     `json:"admin_username"`
+	AdminPassword        string                     `json:"admin_password"`
+	DiscoveryInterval    int                        `json:"discovery_interval"`
+	DiscoveryGuilds      []DiscoveryGuild           `json:"discovery_guilds"`
+	DiscoveryCharacters  int                        `json:"discovery_characters"`
+	StorePulls           bool                       `json:"store_pulls"`
+	ReleaseDates         map[int64]string           `json:"encounter_release_dates"`
+	LodestoneBaseURL     string                     `json:"lodestone_base_url"`
+	LodestoneFixtureFile string                     `json:"lodestone_fixture_file"`
+}
+
+// ReportLiveWindowDuration is how soon after a report's last fight an import must happen for the report to count as live.
+func (c Config) ReportLiveWindowDuration() time.Duration {
+	return time.Duration(c.ReportLiveWindow) * time.Minute
+}
+
+// ReportRefreshCooldownDuration is how long to wait before a live report can be imported again.
+func (c Config) ReportRefreshCooldownDuration() time.Duration {
+	return time.Duration(c.ReportRefreshDelay) * time.Minute
+}
+
+// EncounterReleaseDate returns the configured release date for the given boss id.
+func (c Config) EncounterReleaseDate(bossID int64) (time.Time, bool) {
+	releaseDate, ok := c.ReleaseDates[bossID]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02", releaseDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
 }
 
 func LoadConfig() (Config, error) {
@@ -31,5 +82,31 @@ func LoadConfig() (Config, error) {
 	if err := json.Unmarshal(rawConfigData, &config); err != nil {
 		return config, err
 	}
+	if config.FFLogsAPIVersion == "" {
+		config.FFLogsAPIVersion = FFLogsAPIVersion1
+	}
+	if config.FFLogsBaseURL == "" {
+		config.FFLogsBaseURL = "https://www.fflogs.com"
+	}
+	config.FFLogsBaseURL = strings.TrimSuffix(config.FFLogsBaseURL, "/")
+	if config.LodestoneBaseURL == "" {
+		config.LodestoneBaseURL = "https://na.finalfantasyxiv.com"
+	}
+	config.LodestoneBaseURL = strings.TrimSuffix(config.LodestoneBaseURL, "/")
+	if config.ImportMaxAttempts <= 0 {
+		config.ImportMaxAttempts = 5
+	}
+	if config.ImportWorkers <= 0 {
+		config.ImportWorkers = 2
+	}
+	if config.FFLogsRateLimit <= 0 {
+		config.FFLogsRateLimit = 30
+	}
+	if config.ReportLiveWindow <= 0 {
+		config.ReportLiveWindow = 60
+	}
+	if config.ReportRefreshDelay <= 0 {
+		config.ReportRefreshDelay = 15
+	}
 	return config, nil
 }
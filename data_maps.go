@@ -3,34 +3,45 @@ package main
 import (
 	"encoding/json"
 	"os"
+	"sync"
 )
 
 const dcServerMapJson = "data/dc_servers.json"
 const dcRegionMapJson = "data/dc_regions.json"
+const jobMapJson = "data/job_map.json"
 
 var dcServerMap = map[string][]string{}
 var dcRegionMap = map[string]string{}
 
-func fetchDCServerMap() error {
-	dcServerMap = make(map[string][]string)
-	rawData, err := os.ReadFile(dcServerMapJson)
+// dataMapLock guards the data maps so they can be reloaded while serving requests
+var dataMapLock sync.RWMutex
+
+func readDataMap(path string, out interface{}) error {
+	rawData, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	if err := json.Unmarshal(rawData, &dcServerMap); err != nil {
-		return err
-	}
-	return nil
+	return json.Unmarshal(rawData, out)
 }
 
-func fetchDCRegionMap() error {
-	dcRegionMap = make(map[string]string)
-	rawData, err := os.ReadFile(dcRegionMapJson)
-	if err != nil {
+// LoadDataMaps reads all data maps from disk, the current maps are kept if any of them fail to load.
+func LoadDataMaps() error {
+	newDCServerMap := make(map[string][]string)
+	if err := readDataMap(dcServerMapJson, &newDCServerMap); err != nil {
+		return err
+	}
+	newDCRegionMap := make(map[string]string)
+	if err := readDataMap(dcRegionMapJson, &newDCRegionMap); err != nil {
 		return err
 	}
-	if err := json.Unmarshal(rawData, &dcRegionMap); err != nil {
+	newJobsMap := make(map[string]string)
+	if err := readDataMap(jobMapJson, &newJobsMap); err != nil {
 		return err
 	}
+	dataMapLock.Lock()
+	defer dataMapLock.Unlock()
+	dcServerMap = newDCServerMap
+	dcRegionMap = newDCRegionMap
+	JobsMap = newJobsMap
 	return nil
 }
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EncounterInfo struct {
@@ -15,9 +19,18 @@ type EncounterInfo struct {
 	BossID      int64  `json:"-"`
 	ZoneID      int64  `json:"zone_id"`
 	ZoneName    string `json:"zone_name" gorm:"index:idx_encounter_info_zone_name"`
+	DisplayName string `json:"display_name"`
 	Difficulty  int64  `json:"-"`
 }
 
+// Name returns the display name set by an admin, falling back to the zone name from fflogs.
+func (e EncounterInfo) Name() string {
+	if e.DisplayName != "" {
+		return e.DisplayName
+	}
+	return e.ZoneName
+}
+
 func (e EncounterInfo) IsDisplayable() bool {
 	return strings.Contains(e.ZoneName, "Savage") || strings.Contains(e.ZoneName, "Ultimate") || strings.Contains(e.ZoneName, "Extreme")
 }
@@ -44,20 +57,294 @@ func (prevProg CharacterProgression) IsImprovement(newProg CharacterProgression)
 	return (!prevProg.IsKill && newProg.IsKill) || (prevProg.IsKill && newProg.IsKill && newProg.Duration < prevProg.Duration) || (!prevProg.IsKill && !newProg.IsKill && newProg.FightPercentage < prevProg.FightPercentage)
 }
 
+// CharacterPull is a single attempt at an encounter, only recorded when pull storage is enabled.
+type CharacterPull struct {
+	gorm.Model
+	CharacterID     uint          `json:"-" gorm:"index:idx_character_pull_character_id_report_id_fight_id,unique;index:idx_character_pull_character_id_encounter_info_id"`
+	ReportID        string        `json:"report_id" gorm:"index:idx_character_pull_character_id_report_id_fight_id,unique"`
+	FightID         int64         `json:"fight_id" gorm:"index:idx_character_pull_character_id_report_id_fight_id,unique"`
+	EncounterInfoID uint          `json:"-" gorm:"index:idx_character_pull_character_id_encounter_info_id"`
+	EncounterInfo   EncounterInfo `json:"encounter"`
+	StartTime       time.Time     `json:"start_time"`
+	EndTime         time.Time     `json:"end_time"`
+	Duration        int64         `json:"duration"`
+	FightPercentage int64         `json:"fight_percentage"`
+	Phase           int64         `json:"phase"`
+	PhasePercentage int64         `json:"phase_percentage"`
+	IsKill          bool          `json:"is_kill"`
+	Job             string        `json:"job"`
+}
+
+// CharacterEncounterStats contains pull statistics for a character in an encounter, derived from stored pulls.
+type CharacterEncounterStats struct {
+	EncounterInfoID  uint  `json:"encounter_id"`
+	Pulls            int64 `json:"pulls"`
+	CombatTime       int64 `json:"combat_time"`
+	Reports          int64 `json:"reports"`
+	Days             int64 `json:"days"`
+	PullsToFirstKill int64 `json:"pulls_to_first_kill"`
+}
+
 type Character struct {
 	gorm.Model
-	UID         string `json:"uid" gorm:"index:idx_character_uid,unique"`
-	CompareHash string `json:"-" gorm:"index:idx_character_compare_hash,unique"`
-	Name        string `json:"name"`
-	Server      string `json:"server"`
+	UID          string `json:"uid" gorm:"index:idx_character_uid,unique"`
+	CompareHash  string `json:"-" gorm:"index:idx_character_compare_hash,unique"`
+	Name         string `json:"name"`
+	Server       string `json:"server"`
+	GameID       int64  `json:"-" gorm:"index:idx_character_game_id"`
+	MergedIntoID *uint  `json:"-" gorm:"index:idx_character_merged_into_id"`
+	DisplayJob   string `json:"display_job"`
+	AltOfID      *uint  `json:"-" gorm:"index:idx_character_alt_of_id"`
+	Hidden       bool   `json:"-"`
+}
+
+// IsMerged returns true if the character was linked to another character record, which is now used in its place.
+func (c Character) IsMerged() bool {
+	return c.MergedIntoID != nil
+}
+
+// CharacterClaim is a request to own a character, verified by placing the token in the character's Lodestone bio.
+type CharacterClaim struct {
+	gorm.Model
+	CharacterID  uint       `gorm:"index:idx_character_claim_character_id_owner_key_hash,unique"`
+	OwnerKeyHash string     `gorm:"index:idx_character_claim_character_id_owner_key_hash,unique;index:idx_character_claim_owner_key_hash"`
+	Token        string     ``
+	LodestoneID  int64      ``
+	VerifiedAt   *time.Time ``
+}
+
+func (c CharacterClaim) IsVerified() bool {
+	return c.VerifiedAt != nil
+}
+
+// CharacterOptOut stops future reports from importing a hidden character, matched by name and server or game id.
+type CharacterOptOut struct {
+	gorm.Model
+	CharacterID uint   `gorm:"index:idx_character_opt_out_character_id"`
+	CompareHash string `gorm:"index:idx_character_opt_out_compare_hash"`
+	GameID      int64  `gorm:"index:idx_character_opt_out_game_id"`
+}
+
+// CharacterHiddenEncounter is an encounter the owner of a character has hidden from their page.
+type CharacterHiddenEncounter struct {
+	gorm.Model
+	CharacterID     uint `gorm:"index:idx_character_hidden_encounter_character_id_encounter_info_id,unique"`
+	EncounterInfoID uint `gorm:"index:idx_character_hidden_encounter_character_id_encounter_info_id,unique"`
+}
+
+// CharacterAlias is a name and server a character has been seen under.
+type CharacterAlias struct {
+	gorm.Model
+	CharacterID uint      `json:"-" gorm:"index:idx_character_alias_character_id;index:idx_character_alias_compare_hash_character_id,unique,priority:2"`
+	CompareHash string    `json:"-" gorm:"index:idx_character_alias_compare_hash_character_id,unique,priority:1"`
+	Name        string    `json:"name"`
+	Server      string    `json:"server"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+const (
+	LeaderboardEarliestClear = "clear"
+	LeaderboardFastestKill   = "speed"
+)
+
+// LeaderboardEntry is a character's placing on an encounter leaderboard.
+type LeaderboardEntry struct {
+	Rank         int64     `json:"rank"`
+	UID          string    `json:"uid"`
+	Name         string    `json:"name"`
+	Server       string    `json:"server"`
+	Job          string    `json:"job"`
+	ReportID     string    `json:"report_id"`
+	Time         time.Time `json:"time"`
+	Duration     int64     `json:"duration"`
+	SinceRelease int64     `json:"since_release" gorm:"-"`
+}
+
+// EncounterDistribution summarizes where every tracked character stands on an encounter.
+type EncounterDistribution struct {
+	Characters        int64                      `json:"characters"`
+	Clears            int64                      `json:"clears"`
+	Phases            []EncounterPhaseCount      `json:"phases"`
+	Histogram         []EncounterHistogramBucket `json:"histogram"`
+	ClearsOverTime    []EncounterClearDay        `json:"clears_over_time"`
+	MedianDaysToClear float64                    `json:"median_days_to_clear"`
+}
+
+// EncounterPhaseCount is the number of characters whose best attempt ended in a phase.
+type EncounterPhaseCount struct {
+	Phase      int64 `json:"phase"`
+	Characters int64 `json:"characters"`
+}
+
+// EncounterHistogramBucket is the number of characters whose best attempt ended within a 10% range of the fight.
+type EncounterHistogramBucket struct {
+	Bucket     int64 `json:"bucket"`
+	Characters int64 `json:"characters"`
+	Share      int64 `json:"-" gorm:"-"`
+}
+
+// EncounterClearDay is the number of characters that first cleared an encounter on a day.
+type EncounterClearDay struct {
+	Day    string `json:"day"`
+	Clears int64  `json:"clears"`
+	Total  int64  `json:"total"`
+}
+
+// visibleProgressionsCondition leaves out hidden characters and encounters their owner has hidden, as the leaderboard does.
+const visibleProgressionsCondition = `
+	character_id IN (SELECT id FROM characters WHERE NOT hidden AND deleted_at IS NULL)
+	AND NOT EXISTS (SELECT 1 FROM character_hidden_encounters WHERE character_hidden_encounters.character_id = character_progressions.character_id AND character_hidden_encounters.encounter_info_id = character_progressions.encounter_info_id)`
+
+// bestEncounterProgressionsQuery ranks every visible character's progressions for an encounter, best first.
+const bestEncounterProgressionsQuery = `
+	WITH best AS (
+		SELECT character_id, is_kill, fight_percentage, phase,
+			ROW_NUMBER() OVER (PARTITION BY character_id ORDER BY is_kill desc, fight_percentage asc) AS prog_rank
+		FROM character_progressions
+		WHERE encounter_info_id = ? AND deleted_at IS NULL AND ` + visibleProgressionsCondition + `
+	)`
+
+// maxMergeDepth limits how many merged characters are followed to find the current record.
+const maxMergeDepth = 10
+
+// staticPartySize is the number of characters in a full party.
+const staticPartySize = 8
+
+// staticMinReports is the number of reports a party must appear in before it is considered a static.
+const staticMinReports = 2
+
+// Static is a full party of characters that has been seen together in imported reports.
+type Static struct {
+	gorm.Model
+	UID         string      `json:"uid" gorm:"index:idx_static_uid,unique"`
+	CompareHash string      `json:"-" gorm:"index:idx_static_compare_hash,unique"`
+	Name        string      `json:"name"`
+	ClaimedBy   string      `json:"-"`
+	ClaimedAt   time.Time   `json:"claimed_at"`
+	ReportCount int         `json:"report_count"`
+	Members     []Character `json:"members" gorm:"many2many:static_members"`
+}
+
+func (s Static) DisplayName() string {
+	if s.Name == "" {
+		return "Unnamed Static"
+	}
+	return s.Name
+}
+
+func (s Static) IsClaimed() bool {
+	return s.Name != ""
+}
+
+// IsRecurring returns true if the party has played together often enough to be displayed as a static.
+func (s Static) IsRecurring() bool {
+	return s.ReportCount >= staticMinReports
+}
+
+// StaticProgression is a static's best attempt at an encounter in a single report.
+type StaticProgression struct {
+	gorm.Model
+	StaticID        uint          `json:"-" gorm:"index:idx_static_progression_static_id_report_id_encounter_info_id,unique"`
+	ReportID        string        `json:"report_id" gorm:"index:idx_static_progression_static_id_report_id_encounter_info_id,unique"`
+	EncounterInfoID uint          `json:"-" gorm:"index:idx_static_progression_static_id_report_id_encounter_info_id,unique"`
+	EncounterInfo   EncounterInfo `json:"encounter"`
+	Time            time.Time     `json:"time"`
+	FightPercentage int64         `json:"fight_percentage"`
+	Phase           int64         `json:"phase"`
+	PhasePercentage int64         `json:"phase_percentage"`
+	Duration        int64         `json:"duration"`
+	IsKill          bool          `json:"is_kill"`
+	Pulls           int           `json:"pulls"`
+}
+
+const (
+	ImportStatusQueued   = "queued"
+	ImportStatusFetching = "fetching"
+	ImportStatusWriting  = "writing"
+	ImportStatusRetrying = "retrying"
+	ImportStatusDone     = "done"
+	ImportStatusFailed   = "failed"
+	ImportStatusRemoved  = "removed"
+)
+
+type ImportJob struct {
+	gorm.Model
+	ReportID         string    `json:"report_id" gorm:"index:idx_import_job_report_id,unique"`
+	Status           string    `json:"status" gorm:"index:idx_import_job_status"`
+	Attempts         int       `json:"attempts"`
+	SubmitterIP      string    `json:"-"`
+	EnqueuedAt       time.Time `json:"enqueued_at"`
+	NextAttemptAt    time.Time `json:"next_attempt_at"`
+	LastError        string    `json:"last_error"`
+	PermanentFailure bool      `json:"permanent_failure"`
+	CharacterCount   int       `json:"character_count"`
+	ProgressionCount int       `json:"progression_count"`
+}
+
+func (j ImportJob) IsPending() bool {
+	return j.Status == ImportStatusQueued || j.Status == ImportStatusFetching || j.Status == ImportStatusWriting || j.Status == ImportStatusRetrying
+}
+
+const (
+	ReportOutcomeImported       = "imported"
+	ReportOutcomeNoImprovements = "no_improvements"
+	ReportOutcomeNoEncounters   = "no_encounters"
+)
+
+type Report struct {
+	gorm.Model
+	ReportID         string    `json:"report_id" gorm:"index:idx_report_report_id,unique"`
+	Title            string    `json:"title"`
+	Owner            string    `json:"owner"`
+	ZoneID           int64     `json:"zone_id"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+	GameVersion      int64     `json:"game_version"`
+	ImportedAt       time.Time `json:"imported_at"`
+	Outcome          string    `json:"outcome"`
+	CharacterCount   int       `json:"character_count"`
+	ProgressionCount int       `json:"progression_count"`
+}
+
+// IsLive returns true if the report was still being logged when it was last imported.
+func (r Report) IsLive(liveWindow time.Duration) bool {
+	return r.ImportedAt.Sub(r.EndTime) < liveWindow
+}
+
+// CanRefresh returns true if a live report is ready to be imported again to pick up new fights.
+func (r Report) CanRefresh(liveWindow time.Duration, cooldown time.Duration) bool {
+	return r.IsLive(liveWindow) && time.Since(r.ImportedAt) >= cooldown
+}
+
+func (r Report) DisplayTitle() string {
+	if r.Title == "" {
+		return r.ReportID
+	}
+	return r.Title
 }
 
 type DatabaseHandler struct {
-	Conn *gorm.DB
+	Conn       *gorm.DB
+	storePulls bool
+}
+
+// IsDatabaseBusyError returns true if a write failed because another connection held the database lock.
+func IsDatabaseBusyError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
 }
 
 func NewDatabaserHandler(config *Config) (*DatabaseHandler, error) {
-	db, err := gorm.Open(sqlite.Open(config.DatabaseFile), &gorm.Config{})
+	// import workers write concurrently, wait on locks instead of failing
+	// transactions take the write lock up front as upgrading a read lock fails without waiting
+	dsn := config.DatabaseFile
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000&_txlock=immediate"
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
@@ -70,9 +357,79 @@ func NewDatabaserHandler(config *Config) (*DatabaseHandler, error) {
 	if err := db.AutoMigrate(&Character{}); err != nil {
 		return nil, err
 	}
-	return &DatabaseHandler{
-		Conn: db,
-	}, nil
+	if err := db.AutoMigrate(&ImportJob{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&Report{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&CharacterPull{}); err != nil {
+		return nil, err
+	}
+	// different characters can share a name and server, aliases were unique by it before
+	if db.Migrator().HasIndex(&CharacterAlias{}, "idx_character_alias_compare_hash") {
+		if err := db.Migrator().DropIndex(&CharacterAlias{}, "idx_character_alias_compare_hash"); err != nil {
+			return nil, err
+		}
+	}
+	if err := db.AutoMigrate(&CharacterAlias{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&CharacterClaim{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&CharacterHiddenEncounter{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&CharacterOptOut{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&Static{}); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&StaticProgression{}); err != nil {
+		return nil, err
+	}
+	d := &DatabaseHandler{
+		Conn:       db,
+		storePulls: config.StorePulls,
+	}
+	if err := d.normalizeJobs(); err != nil {
+		return nil, err
+	}
+	if err := d.backfillCharacterAliases(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// backfillCharacterAliases records the current name of characters imported before alias history was kept.
+func (d DatabaseHandler) backfillCharacterAliases() error {
+	return d.Conn.Exec(`
+		INSERT INTO character_aliases (created_at, updated_at, character_id, compare_hash, name, server, last_seen)
+		SELECT created_at, updated_at, COALESCE(merged_into_id, id), compare_hash, name, server, updated_at
+		FROM characters
+		WHERE deleted_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM character_aliases WHERE character_aliases.compare_hash = characters.compare_hash
+		)`).Error
+}
+
+// normalizeJobs converts jobs stored as raw fflogs types by older versions to job abbreviations.
+func (d DatabaseHandler) normalizeJobs() error {
+	for _, model := range []interface{}{&CharacterProgression{}, &CharacterPull{}} {
+		jobs := make([]string, 0)
+		if tx := d.Conn.Model(model).Distinct().Pluck("job", &jobs); tx.Error != nil {
+			return tx.Error
+		}
+		for _, job := range jobs {
+			if normalizedJob := NormalizeJob(job); normalizedJob != job {
+				if tx := d.Conn.Model(model).Where("job = ?", job).Update("job", normalizedJob); tx.Error != nil {
+					return tx.Error
+				}
+			}
+		}
+	}
+	return nil
 }
 
 func (d DatabaseHandler) FetchEncounterInfoFromCompareHash(hash string) (EncounterInfo, error) {
@@ -81,18 +438,47 @@ func (d DatabaseHandler) FetchEncounterInfoFromCompareHash(hash string) (Encount
 	return encounterInfo, tx.Error
 }
 
+func (d DatabaseHandler) FetchCharacterFromID(id uint) (Character, error) {
+	character := Character{}
+	tx := d.Conn.First(&character, id)
+	return character, tx.Error
+}
+
 func (d DatabaseHandler) FetchCharacterFromUID(uid string) (Character, error) {
 	character := Character{}
 	tx := d.Conn.First(&character, "uid = ?", uid)
 	return character, tx.Error
 }
 
-func (d DatabaseHandler) FetchBestCharacterProgressionForEncounter(characterID uint, encounterID uint) (CharacterProgression, error) {
+func (d DatabaseHandler) FetchBestCharacterProgressionForEncounterJob(characterID uint, encounterID uint, job string) (CharacterProgression, error) {
 	results := CharacterProgression{}
-	tx := d.Conn.Where("character_id = ? AND encounter_info_id = ?", characterID, encounterID).Order("is_kill desc, fight_percentage asc, time desc").Preload("EncounterInfo").First(&results)
+	tx := d.Conn.Where("character_id = ? AND encounter_info_id = ? AND job = ?", characterID, encounterID, job).Order("is_kill desc, fight_percentage asc, time desc").Preload("EncounterInfo").First(&results)
 	return results, tx.Error
 }
 
+// FetchCharacterJobClears returns the jobs a character has cleared each encounter on, keyed by encounter id.
+func (d DatabaseHandler) FetchCharacterJobClears(characterID uint) (map[uint][]string, error) {
+	results := make([]CharacterProgression, 0)
+	tx := d.Conn.Model(&CharacterProgression{}).Select("DISTINCT encounter_info_id, job").Where("character_id = ? AND is_kill AND job != ''", characterID).Order("job asc").Find(&results)
+	out := make(map[uint][]string)
+	for _, characterProgression := range results {
+		out[characterProgression.EncounterInfoID] = append(out[characterProgression.EncounterInfoID], characterProgression.Job)
+	}
+	return out, tx.Error
+}
+
+func (d DatabaseHandler) FetchCharacterProgressionsForEncounter(characterID uint, encounterID uint) ([]CharacterProgression, error) {
+	results := make([]CharacterProgression, 0)
+	tx := d.Conn.Where("character_id = ? AND encounter_info_id = ?", characterID, encounterID).Order("time asc").Preload("EncounterInfo").Find(&results)
+	return results, tx.Error
+}
+
+func (d DatabaseHandler) FetchEncounterInfo(encounterID uint) (EncounterInfo, error) {
+	encounterInfo := EncounterInfo{}
+	tx := d.Conn.First(&encounterInfo, encounterID)
+	return encounterInfo, tx.Error
+}
+
 func (d DatabaseHandler) FetchBestCharacterProgressions(characterID uint) ([]CharacterProgression, error) {
 	results := make([]CharacterProgression, 0)
 	tx := d.Conn.Where("character_id = ?", characterID).Order("is_kill desc, fight_percentage asc, time desc").Preload("EncounterInfo").Find(&results)
@@ -117,15 +503,281 @@ func (d DatabaseHandler) FetchBestCharacterProgressions(characterID uint) ([]Cha
 	return out, nil
 }
 
+func (d DatabaseHandler) FetchRecentlyUpdatedCharacters(limit int) ([]Character, error) {
+	results := make([]Character, 0)
+	tx := d.Conn.Where("merged_into_id IS NULL AND NOT hidden").Order("updated_at desc").Limit(limit).Find(&results)
+	return results, tx.Error
+}
+
+func (d DatabaseHandler) FetchCharacterPullsForEncounter(characterID uint, encounterID uint) ([]CharacterPull, error) {
+	results := make([]CharacterPull, 0)
+	tx := d.Conn.Where("character_id = ? AND encounter_info_id = ?", characterID, encounterID).Order("start_time asc").Find(&results)
+	return results, tx.Error
+}
+
+// FetchCharacterEncounterStats returns pull statistics for every encounter a character has pull data for.
+func (d DatabaseHandler) FetchCharacterEncounterStats(characterID uint) (map[uint]CharacterEncounterStats, error) {
+	results := make([]CharacterEncounterStats, 0)
+	tx := d.Conn.Raw(`
+		WITH first_kills AS (
+			SELECT encounter_info_id, MIN(julianday(start_time)) AS first_kill
+			FROM character_pulls
+			WHERE character_id = ? AND is_kill AND deleted_at IS NULL
+			GROUP BY encounter_info_id
+		)
+		SELECT
+			p.encounter_info_id,
+			COUNT(*) AS pulls,
+			SUM(p.duration) AS combat_time,
+			COUNT(DISTINCT p.report_id) AS reports,
+			COUNT(DISTINCT date(p.start_time)) AS days,
+			SUM(CASE WHEN julianday(p.start_time) <= fk.first_kill THEN 1 ELSE 0 END) AS pulls_to_first_kill
+		FROM character_pulls p
+		LEFT JOIN first_kills fk ON fk.encounter_info_id = p.encounter_info_id
+		WHERE p.character_id = ? AND p.deleted_at IS NULL
+		GROUP BY p.encounter_info_id`, characterID, characterID).Scan(&results)
+	out := make(map[uint]CharacterEncounterStats)
+	for _, stats := range results {
+		out[stats.EncounterInfoID] = stats
+	}
+	return out, tx.Error
+}
+
+// FetchCharacterFromCompareHash returns the character with the given hash, checking previous names and servers if needed.
 func (d DatabaseHandler) FetchCharacterFromCompareHash(hash string) (Character, error) {
 	character := Character{}
 	tx := d.Conn.First(&character, "compare_hash = ?", hash)
+	if tx.Error != gorm.ErrRecordNotFound {
+		return character, tx.Error
+	}
+	characterAlias := CharacterAlias{}
+	if tx := d.Conn.Order("last_seen desc").First(&characterAlias, "compare_hash = ?", hash); tx.Error != nil {
+		return character, tx.Error
+	}
+	tx = d.Conn.First(&character, characterAlias.CharacterID)
+	return character, tx.Error
+}
+
+// FetchCharacterFromNameServer returns the character last seen with the given name and server, names are case insensitive.
+func (d DatabaseHandler) FetchCharacterFromNameServer(name string, server string) (Character, error) {
+	character := Character{}
+	characterAlias := CharacterAlias{}
+	if tx := d.Conn.Order("last_seen desc").First(&characterAlias, "name = ? COLLATE NOCASE AND server = ? COLLATE NOCASE", name, server); tx.Error != nil {
+		return character, tx.Error
+	}
+	tx := d.Conn.First(&character, characterAlias.CharacterID)
+	return character, tx.Error
+}
+
+func (d DatabaseHandler) FetchCharacterFromGameID(gameID int64) (Character, error) {
+	character := Character{}
+	tx := d.Conn.Order("merged_into_id IS NULL desc, id asc").First(&character, "game_id = ?", gameID)
 	return character, tx.Error
 }
 
-func (d DatabaseHandler) FetchCharacterProgressionFromReportID(reportID string, characterID uint, encounterInfoID uint) (CharacterProgression, error) {
+// FetchCanonicalCharacter follows merged characters to the record that replaced them.
+func (d DatabaseHandler) FetchCanonicalCharacter(character Character) (Character, error) {
+	for i := 0; character.IsMerged() && i < maxMergeDepth; i++ {
+		mergedInto := Character{}
+		if tx := d.Conn.First(&mergedInto, *character.MergedIntoID); tx.Error != nil {
+			return character, tx.Error
+		}
+		character = mergedInto
+	}
+	return character, nil
+}
+
+func (d DatabaseHandler) FetchCharacterAliases(characterID uint) ([]CharacterAlias, error) {
+	results := make([]CharacterAlias, 0)
+	tx := d.Conn.Where("character_id = ?", characterID).Order("last_seen desc").Find(&results)
+	return results, tx.Error
+}
+
+func (d DatabaseHandler) FetchCharacterClaim(characterID uint, ownerKeyHash string) (CharacterClaim, error) {
+	characterClaim := CharacterClaim{}
+	tx := d.Conn.First(&characterClaim, "character_id = ? AND owner_key_hash = ?", characterID, ownerKeyHash)
+	return characterClaim, tx.Error
+}
+
+func (d DatabaseHandler) FetchVerifiedCharacterClaim(characterID uint) (CharacterClaim, error) {
+	characterClaim := CharacterClaim{}
+	tx := d.Conn.First(&characterClaim, "character_id = ? AND verified_at IS NOT NULL", characterID)
+	return characterClaim, tx.Error
+}
+
+func (d DatabaseHandler) SaveCharacterClaim(characterClaim *CharacterClaim) error {
+	return d.Conn.Save(characterClaim).Error
+}
+
+// VerifyCharacterClaim marks a claim as verified, any other claims on the character are removed.
+func (d DatabaseHandler) VerifyCharacterClaim(characterClaim *CharacterClaim, lodestoneID int64) error {
+	return d.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("character_id = ? AND id != ?", characterClaim.CharacterID, characterClaim.ID).Delete(&CharacterClaim{}).Error; err != nil {
+			return err
+		}
+		verifiedAt := time.Now()
+		characterClaim.VerifiedAt = &verifiedAt
+		characterClaim.LodestoneID = lodestoneID
+		return tx.Save(characterClaim).Error
+	})
+}
+
+// FetchOwnedCharacters returns every character verified by the given owner.
+func (d DatabaseHandler) FetchOwnedCharacters(ownerKeyHash string) ([]Character, error) {
+	results := make([]Character, 0)
+	tx := d.Conn.Joins("JOIN character_claims ON character_claims.character_id = characters.id AND character_claims.deleted_at IS NULL").
+		Where("character_claims.owner_key_hash = ? AND character_claims.verified_at IS NOT NULL AND characters.merged_into_id IS NULL", ownerKeyHash).
+		Order("characters.name asc").Find(&results)
+	return results, tx.Error
+}
+
+func (d DatabaseHandler) FetchCharacterHiddenEncounters(characterID uint) (map[uint]bool, error) {
+	results := make([]CharacterHiddenEncounter, 0)
+	tx := d.Conn.Where("character_id = ?", characterID).Find(&results)
+	out := make(map[uint]bool)
+	for _, hiddenEncounter := range results {
+		out[hiddenEncounter.EncounterInfoID] = true
+	}
+	return out, tx.Error
+}
+
+func (d DatabaseHandler) SetCharacterEncounterHidden(characterID uint, encounterID uint, hidden bool) error {
+	if !hidden {
+		return d.Conn.Unscoped().Where("character_id = ? AND encounter_info_id = ?", characterID, encounterID).Delete(&CharacterHiddenEncounter{}).Error
+	}
+	return d.Conn.Clauses(clause.OnConflict{DoNothing: true}).Create(&CharacterHiddenEncounter{CharacterID: characterID, EncounterInfoID: encounterID}).Error
+}
+
+func (d DatabaseHandler) SetCharacterDisplayJob(characterID uint, job string) error {
+	return d.Conn.Model(&Character{}).Where("id = ?", characterID).Update("display_job", job).Error
+}
+
+// SetCharacterAltOf links a character as an alt of another character, nil removes the link.
+func (d DatabaseHandler) SetCharacterAltOf(characterID uint, mainID *uint) error {
+	return d.Conn.Model(&Character{}).Where("id = ?", characterID).Update("alt_of_id", mainID).Error
+}
+
+func (d DatabaseHandler) FetchCharacterAlts(characterID uint) ([]Character, error) {
+	results := make([]Character, 0)
+	tx := d.Conn.Where("alt_of_id = ? AND merged_into_id IS NULL AND NOT hidden", characterID).Order("name asc").Find(&results)
+	return results, tx.Error
+}
+
+// FetchCharacterJobs returns every job a character has progression on.
+func (d DatabaseHandler) FetchCharacterJobs(characterID uint) ([]string, error) {
+	results := make([]string, 0)
+	tx := d.Conn.Model(&CharacterProgression{}).Where("character_id = ? AND job != ''", characterID).Distinct().Order("job asc").Pluck("job", &results)
+	return results, tx.Error
+}
+
+// SetCharacterHidden hides a character from public listings, a hidden character is also opted out of future imports.
+func (d DatabaseHandler) SetCharacterHidden(character Character, hidden bool) error {
+	return d.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Character{}).Where("id = ?", character.ID).Update("hidden", hidden).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("character_id = ?", character.ID).Delete(&CharacterOptOut{}).Error; err != nil {
+			return err
+		}
+		if !hidden {
+			return nil
+		}
+		// every known name is opted out as the game id is not always available
+		characterOptOuts := []CharacterOptOut{{CharacterID: character.ID, CompareHash: character.CompareHash, GameID: character.GameID}}
+		characterAliases := make([]CharacterAlias, 0)
+		if err := tx.Where("character_id = ?", character.ID).Find(&characterAliases).Error; err != nil {
+			return err
+		}
+		for _, characterAlias := range characterAliases {
+			if characterAlias.CompareHash != character.CompareHash {
+				characterOptOuts = append(characterOptOuts, CharacterOptOut{CharacterID: character.ID, CompareHash: characterAlias.CompareHash})
+			}
+		}
+		return tx.Create(&characterOptOuts).Error
+	})
+}
+
+// IsCharacterOptedOut returns true if the character from a report has opted out of being imported.
+func (d DatabaseHandler) IsCharacterOptedOut(character Character) (bool, error) {
+	var count int64
+	tx := d.Conn.Model(&CharacterOptOut{}).Where("compare_hash = ?", character.CompareHash)
+	if character.GameID != 0 {
+		tx = tx.Or("game_id = ?", character.GameID)
+	}
+	tx = tx.Count(&count)
+	return count > 0, tx.Error
+}
+
+// MergeCharacters links the source character to the target character, moving all of its history to the target.
+func (d DatabaseHandler) MergeCharacters(source Character, target Character) error {
+	if source.ID == target.ID {
+		return ErrMergeSameCharacter
+	}
+	return d.Conn.Transaction(func(tx *gorm.DB) error {
+		// characters previously merged in to the source now point at the target
+		if err := tx.Model(&Character{}).Where("merged_into_id = ?", source.ID).Update("merged_into_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Character{}).Where("id = ?", source.ID).Update("merged_into_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Character{}).Where("alt_of_id = ?", source.ID).Update("alt_of_id", target.ID).Error; err != nil {
+			return err
+		}
+		if source.Hidden && !target.Hidden {
+			if err := tx.Model(&Character{}).Where("id = ?", target.ID).Update("hidden", true).Error; err != nil {
+				return err
+			}
+		}
+		if target.GameID == 0 && source.GameID != 0 {
+			if err := tx.Model(&Character{}).Where("id = ?", target.ID).Update("game_id", source.GameID).Error; err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"character_progressions", "character_opt_outs"} {
+			if err := tx.Exec("UPDATE "+table+" SET character_id = ? WHERE character_id = ?", target.ID, source.ID).Error; err != nil {
+				return err
+			}
+		}
+		// a verified claim only moves to a target nobody has verified, the claimant never proved they own the target
+		var targetClaims int64
+		if err := tx.Model(&CharacterClaim{}).Where("character_id = ? AND verified_at IS NOT NULL", target.ID).Count(&targetClaims).Error; err != nil {
+			return err
+		}
+		if targetClaims == 0 {
+			sourceClaim := CharacterClaim{}
+			if err := tx.Where("character_id = ? AND verified_at IS NOT NULL", source.ID).Limit(1).Find(&sourceClaim).Error; err != nil {
+				return err
+			}
+			if sourceClaim.ID != 0 {
+				// pending claims on the target are dropped as they are when a claim is verified
+				if err := tx.Unscoped().Where("character_id = ?", target.ID).Delete(&CharacterClaim{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&sourceClaim).Update("character_id", target.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Unscoped().Where("character_id = ?", source.ID).Delete(&CharacterClaim{}).Error; err != nil {
+			return err
+		}
+		// rows the target already has are dropped
+		for _, table := range []string{"character_aliases", "character_pulls", "static_members", "character_hidden_encounters"} {
+			if err := tx.Exec("UPDATE OR IGNORE "+table+" SET character_id = ? WHERE character_id = ?", target.ID, source.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM "+table+" WHERE character_id = ?", source.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d DatabaseHandler) FetchCharacterProgressionFromReportID(reportID string, characterID uint, encounterInfoID uint, job string) (CharacterProgression, error) {
 	characterProgression := CharacterProgression{}
-	tx := d.Conn.First(&characterProgression, "report_id = ? AND character_id = ? AND encounter_info_id = ?", reportID, characterID, encounterInfoID)
+	tx := d.Conn.First(&characterProgression, "report_id = ? AND character_id = ? AND encounter_info_id = ? AND job = ?", reportID, characterID, encounterInfoID, job)
 	return characterProgression, tx.Error
 }
 
@@ -135,17 +787,492 @@ func (d DatabaseHandler) FetchEncounterList() ([]EncounterInfo, error) {
 	return results, tx.Error
 }
 
+func (d DatabaseHandler) FetchReportFromReportID(reportID string) (Report, error) {
+	report := Report{}
+	tx := d.Conn.First(&report, "report_id = ?", reportID)
+	return report, tx.Error
+}
+
+func (d DatabaseHandler) FetchRecentReports(limit int) ([]Report, error) {
+	results := make([]Report, 0)
+	tx := d.Conn.Order("imported_at desc").Limit(limit).Find(&results)
+	return results, tx.Error
+}
+
+// DeleteReport removes a report along with every progression, pull and static progression recorded from it.
+// Progressions the report had overshadowed are then rebuilt from the remaining stored pulls. The report is still
+// deleted when an affected character played other reports that stored no pulls, ErrProgressionsNotRebuilt is returned
+// as their best progression may be missing.
+func (d DatabaseHandler) DeleteReport(reportID string) error {
+	notRebuilt := false
+	err := d.Conn.Transaction(func(tx *gorm.DB) error {
+		txd := d
+		txd.Conn = tx
+		affectedProgressions := make([]CharacterProgression, 0)
+		if err := tx.Where("report_id = ?", reportID).Find(&affectedProgressions).Error; err != nil {
+			return err
+		}
+		staticIDs := make([]uint, 0)
+		if err := tx.Model(&StaticProgression{}).Where("report_id = ?", reportID).Distinct().Pluck("static_id", &staticIDs).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&CharacterProgression{}, &CharacterPull{}, &StaticProgression{}, &Report{}, &ImportJob{}} {
+			if err := tx.Unscoped().Where("report_id = ?", reportID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		// statics no longer seen together are left for their remaining reports
+		for _, staticID := range staticIDs {
+			if err := tx.Exec("UPDATE statics SET report_count = (SELECT COUNT(DISTINCT report_id) FROM static_progressions WHERE static_id = ? AND deleted_at IS NULL) WHERE id = ?", staticID, staticID).Error; err != nil {
+				return err
+			}
+		}
+		for _, characterProgression := range affectedProgressions {
+			if err := txd.rebuildCharacterProgressions(characterProgression.CharacterID, characterProgression.EncounterInfoID, characterProgression.Job); err != nil {
+				return err
+			}
+			if !notRebuilt {
+				hasReports, err := txd.hasReportsWithoutPulls(characterProgression.CharacterID, characterProgression.EncounterInfoID)
+				if err != nil {
+					return err
+				}
+				notRebuilt = hasReports
+			}
+		}
+		return nil
+	})
+	if err == nil && notRebuilt {
+		return ErrProgressionsNotRebuilt
+	}
+	return err
+}
+
+// hasReportsWithoutPulls returns true if a character played reports that stored no pulls for them, either with
+// a progression of their own or with a static that progressed the encounter.
+func (d DatabaseHandler) hasReportsWithoutPulls(characterID uint, encounterID uint) (bool, error) {
+	var count int64
+	tx := d.Conn.Raw(`
+		SELECT COUNT(*) FROM (
+			SELECT report_id FROM character_progressions WHERE character_id = ? AND deleted_at IS NULL
+			UNION
+			SELECT static_progressions.report_id FROM static_progressions
+			JOIN static_members ON static_members.static_id = static_progressions.static_id
+			WHERE static_members.character_id = ? AND static_progressions.encounter_info_id = ? AND static_progressions.deleted_at IS NULL
+		) AS played
+		WHERE NOT EXISTS (
+			SELECT 1 FROM character_pulls WHERE character_pulls.character_id = ? AND character_pulls.report_id = played.report_id AND character_pulls.deleted_at IS NULL
+		)`, characterID, characterID, encounterID, characterID).Scan(&count)
+	return count > 0, tx.Error
+}
+
+// rebuildCharacterProgressions replays a character's progression for an encounter and job in time order,
+// adding a progression for any report with stored pulls that is now an improvement.
+func (d DatabaseHandler) rebuildCharacterProgressions(characterID uint, encounterID uint, job string) error {
+	characterProgressions := make([]CharacterProgression, 0)
+	if err := d.Conn.Where("character_id = ? AND encounter_info_id = ? AND job = ?", characterID, encounterID, job).Find(&characterProgressions).Error; err != nil {
+		return err
+	}
+	characterPulls := make([]CharacterPull, 0)
+	if err := d.Conn.Where("character_id = ? AND encounter_info_id = ? AND job = ?", characterID, encounterID, job).Order("start_time asc").Find(&characterPulls).Error; err != nil {
+		return err
+	}
+	// reports that already have a progression are kept as is
+	candidates := make(map[string]CharacterProgression)
+	for _, characterProgression := range characterProgressions {
+		candidates[characterProgression.ReportID] = characterProgression
+	}
+	pullsByReport := make(map[string][]CharacterPull)
+	for _, characterPull := range characterPulls {
+		if _, ok := candidates[characterPull.ReportID]; !ok {
+			pullsByReport[characterPull.ReportID] = append(pullsByReport[characterPull.ReportID], characterPull)
+		}
+	}
+	for reportID, reportPulls := range pullsByReport {
+		candidates[reportID] = characterProgressionFromPulls(reportPulls)
+	}
+	ordered := make([]CharacterProgression, 0, len(candidates))
+	for _, characterProgression := range candidates {
+		ordered = append(ordered, characterProgression)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Time.Before(ordered[j].Time)
+	})
+	var best *CharacterProgression
+	for i := range ordered {
+		characterProgression := ordered[i]
+		isImprovement := best == nil || best.IsImprovement(characterProgression)
+		if isImprovement {
+			best = &ordered[i]
+		}
+		if characterProgression.ID != 0 || !isImprovement {
+			continue
+		}
+		report, err := d.FetchReportFromReportID(characterProgression.ReportID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		// composition is not stored with pulls, use what was recorded for the rest of the party
+		partyProgression := CharacterProgression{}
+		if err := d.Conn.Where("report_id = ? AND encounter_info_id = ?", characterProgression.ReportID, encounterID).Limit(1).Find(&partyProgression).Error; err != nil {
+			return err
+		}
+		characterProgression.CharacterID = characterID
+		characterProgression.EncounterInfoID = encounterID
+		characterProgression.Job = job
+		characterProgression.GameVersion = report.GameVersion
+		characterProgression.IsStandardComposition = partyProgression.IsStandardComposition
+		if err := d.Conn.Omit(clause.Associations).Create(&characterProgression).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// characterProgressionFromPulls returns the best attempt out of a single report's pulls, picked the same way as an import.
+func characterProgressionFromPulls(characterPulls []CharacterPull) CharacterProgression {
+	out := CharacterProgression{FightPercentage: -1, Duration: -1}
+	hasKill := false
+	for _, characterPull := range characterPulls {
+		hasKill = hasKill || characterPull.IsKill
+	}
+	out.IsKill = hasKill
+	for _, characterPull := range characterPulls {
+		if hasKill && !characterPull.IsKill {
+			continue
+		}
+		out.ReportID = characterPull.ReportID
+		if (hasKill && (characterPull.Duration < out.Duration || out.Duration == -1)) || (!hasKill && characterPull.Duration > out.Duration) {
+			out.Duration = characterPull.Duration
+		}
+		if out.FightPercentage < 0 || out.FightPercentage > characterPull.FightPercentage {
+			out.FightPercentage = characterPull.FightPercentage
+			out.Phase = characterPull.Phase
+			out.PhasePercentage = characterPull.PhasePercentage
+			out.Time = characterPull.EndTime
+		}
+	}
+	return out
+}
+
+func (d DatabaseHandler) SaveEncounterDisplayName(encounterID uint, displayName string) error {
+	return d.Conn.Model(&EncounterInfo{}).Where("id = ?", encounterID).Update("display_name", displayName).Error
+}
+
+func (d DatabaseHandler) FetchReportsForCharacterProgressions(characterProgressions []CharacterProgression) (map[string]Report, error) {
+	reportIDs := make([]string, 0, len(characterProgressions))
+	for _, characterProgression := range characterProgressions {
+		reportIDs = append(reportIDs, characterProgression.ReportID)
+	}
+	results := make([]Report, 0)
+	tx := d.Conn.Where("report_id IN ?", reportIDs).Find(&results)
+	out := make(map[string]Report)
+	for _, report := range results {
+		out[report.ReportID] = report
+	}
+	return out, tx.Error
+}
+
+// FetchLeaderboard ranks characters that cleared an encounter by their earliest clear or fastest kill.
+// Servers and job are optional filters.
+func (d DatabaseHandler) FetchLeaderboard(encounterID uint, leaderboardType string, servers []string, job string, limit int) ([]LeaderboardEntry, error) {
+	results := make([]LeaderboardEntry, 0)
+	orderColumn := "time"
+	if leaderboardType == LeaderboardFastestKill {
+		orderColumn = "duration"
+	}
+	best := d.Conn.Table("character_progressions").
+		Select("characters.uid, characters.name, characters.server, character_progressions.job, character_progressions.report_id, character_progressions.time, character_progressions.duration, "+
+			"ROW_NUMBER() OVER (PARTITION BY character_progressions.character_id ORDER BY character_progressions."+orderColumn+" asc, character_progressions.id asc) AS character_rank").
+		Joins("JOIN characters ON characters.id = character_progressions.character_id AND characters.deleted_at IS NULL AND NOT characters.hidden").
+		Where("character_progressions.encounter_info_id = ? AND character_progressions.is_kill AND character_progressions.deleted_at IS NULL", encounterID).
+		Where("NOT EXISTS (SELECT 1 FROM character_hidden_encounters WHERE character_hidden_encounters.character_id = character_progressions.character_id AND character_hidden_encounters.encounter_info_id = character_progressions.encounter_info_id)")
+	if servers != nil {
+		best = best.Where("characters.server IN ?", servers)
+	}
+	if job != "" {
+		best = best.Where("character_progressions.job = ?", job)
+	}
+	tx := d.Conn.Table("(?) AS best_progressions", best).
+		Select("*, RANK() OVER (ORDER BY " + orderColumn + " asc) AS rank").
+		Where("character_rank = 1").
+		Order("rank asc, name asc").
+		Limit(limit).
+		Scan(&results)
+	return results, tx.Error
+}
+
+// FetchEncounterFirstTime returns the time of the earliest recorded progression for an encounter.
+func (d DatabaseHandler) FetchEncounterFirstTime(encounterID uint) (time.Time, error) {
+	characterProgression := CharacterProgression{}
+	tx := d.Conn.Where("encounter_info_id = ?", encounterID).Order("time asc").First(&characterProgression)
+	return characterProgression.Time, tx.Error
+}
+
+// FetchEncounterJobs returns every job that has cleared an encounter.
+func (d DatabaseHandler) FetchEncounterJobs(encounterID uint) ([]string, error) {
+	results := make([]string, 0)
+	tx := d.Conn.Model(&CharacterProgression{}).Where("encounter_info_id = ? AND is_kill", encounterID).Distinct().Order("job asc").Pluck("job", &results)
+	return results, tx.Error
+}
+
+// FetchEncounterDistribution returns the distribution of every visible character's best progression for an encounter.
+func (d DatabaseHandler) FetchEncounterDistribution(encounterID uint) (EncounterDistribution, error) {
+	out := EncounterDistribution{}
+	totals := struct {
+		Characters int64
+		Clears     int64
+	}{}
+	if tx := d.Conn.Raw(bestEncounterProgressionsQuery+`
+		SELECT COUNT(*) AS characters, COALESCE(SUM(is_kill), 0) AS clears
+		FROM best WHERE prog_rank = 1`, encounterID).Scan(&totals); tx.Error != nil {
+		return out, tx.Error
+	}
+	out.Characters = totals.Characters
+	out.Clears = totals.Clears
+	out.Phases = make([]EncounterPhaseCount, 0)
+	if tx := d.Conn.Raw(bestEncounterProgressionsQuery+`
+		SELECT phase, COUNT(*) AS characters
+		FROM best WHERE prog_rank = 1 AND NOT is_kill
+		GROUP BY phase ORDER BY phase asc`, encounterID).Scan(&out.Phases); tx.Error != nil {
+		return out, tx.Error
+	}
+	// fight percentage is stored as 0-10000, bucket into 10% ranges
+	buckets := make([]EncounterHistogramBucket, 0)
+	if tx := d.Conn.Raw(bestEncounterProgressionsQuery+`
+		SELECT MIN(fight_percentage / 1000, 9) AS bucket, COUNT(*) AS characters
+		FROM best WHERE prog_rank = 1 AND NOT is_kill
+		GROUP BY bucket`, encounterID).Scan(&buckets); tx.Error != nil {
+		return out, tx.Error
+	}
+	out.Histogram = make([]EncounterHistogramBucket, 10)
+	for i := range out.Histogram {
+		out.Histogram[i].Bucket = int64(i)
+	}
+	for _, bucket := range buckets {
+		out.Histogram[bucket.Bucket].Characters = bucket.Characters
+		if wipes := out.Characters - out.Clears; wipes > 0 {
+			out.Histogram[bucket.Bucket].Share = bucket.Characters * 100 / wipes
+		}
+	}
+	out.ClearsOverTime = make([]EncounterClearDay, 0)
+	if tx := d.Conn.Raw(`
+		WITH first_clears AS (
+			SELECT date(MIN(time)) AS day
+			FROM character_progressions
+			WHERE encounter_info_id = ? AND is_kill AND deleted_at IS NULL AND `+visibleProgressionsCondition+`
+			GROUP BY character_id
+		)
+		SELECT day, COUNT(*) AS clears, SUM(COUNT(*)) OVER (ORDER BY day) AS total
+		FROM first_clears GROUP BY day ORDER BY day asc`, encounterID).Scan(&out.ClearsOverTime); tx.Error != nil {
+		return out, tx.Error
+	}
+	tx := d.Conn.Raw(`
+		WITH days_to_clear AS (
+			SELECT julianday(MIN(CASE WHEN is_kill THEN time END)) - julianday(MIN(time)) AS days
+			FROM character_progressions
+			WHERE encounter_info_id = ? AND deleted_at IS NULL AND `+visibleProgressionsCondition+`
+			GROUP BY character_id
+			HAVING MAX(is_kill)
+		),
+		ordered AS (
+			SELECT days, ROW_NUMBER() OVER (ORDER BY days) AS row_num, COUNT(*) OVER () AS row_count
+			FROM days_to_clear
+		)
+		SELECT COALESCE(AVG(days), 0) FROM ordered
+		WHERE row_num IN ((row_count + 1) / 2, (row_count + 2) / 2)`, encounterID).Scan(&out.MedianDaysToClear)
+	return out, tx.Error
+}
+
+// FetchServerCharacterCounts returns the number of tracked characters on every server.
+func (d DatabaseHandler) FetchServerCharacterCounts() (map[string]int64, error) {
+	results := make([]struct {
+		Server     string
+		Characters int64
+	}, 0)
+	tx := d.Conn.Model(&Character{}).Select("server, COUNT(*) AS characters").Where("merged_into_id IS NULL AND NOT hidden").Group("server").Scan(&results)
+	out := make(map[string]int64)
+	for _, result := range results {
+		out[result.Server] = result.Characters
+	}
+	return out, tx.Error
+}
+
+func (d DatabaseHandler) FetchCharactersForServer(server string, limit int, offset int) ([]Character, error) {
+	results := make([]Character, 0)
+	tx := d.Conn.Where("server = ? AND merged_into_id IS NULL AND NOT hidden", server).Order("name asc").Limit(limit).Offset(offset).Find(&results)
+	return results, tx.Error
+}
+
+// FetchCharacterClears returns the encounters each of the given characters has cleared, keyed by character id.
+func (d DatabaseHandler) FetchCharacterClears(characterIDs []uint) (map[uint][]EncounterInfo, error) {
+	results := make([]CharacterProgression, 0)
+	tx := d.Conn.Model(&CharacterProgression{}).Select("DISTINCT character_id, encounter_info_id").
+		Where("character_id IN ? AND is_kill", characterIDs).Order("encounter_info_id asc").Preload("EncounterInfo").Find(&results)
+	out := make(map[uint][]EncounterInfo)
+	for _, characterProgression := range results {
+		if characterProgression.EncounterInfo.IsDisplayable() {
+			out[characterProgression.CharacterID] = append(out[characterProgression.CharacterID], characterProgression.EncounterInfo)
+		}
+	}
+	return out, tx.Error
+}
+
+func (d DatabaseHandler) FetchStaticFromUID(uid string) (Static, error) {
+	static := Static{}
+	tx := d.Conn.Preload("Members").First(&static, "uid = ?", uid)
+	return static, tx.Error
+}
+
+func (d DatabaseHandler) FetchStaticFromCompareHash(hash string) (Static, error) {
+	static := Static{}
+	tx := d.Conn.First(&static, "compare_hash = ?", hash)
+	return static, tx.Error
+}
+
+// FetchStaticsForCharacter returns the statics a character has played in often enough to be displayed.
+func (d DatabaseHandler) FetchStaticsForCharacter(characterID uint) ([]Static, error) {
+	results := make([]Static, 0)
+	tx := d.Conn.Joins("JOIN static_members ON static_members.static_id = statics.id").
+		Where("static_members.character_id = ? AND statics.report_count >= ?", characterID, staticMinReports).
+		Order("statics.report_count desc").Find(&results)
+	return results, tx.Error
+}
+
+// FetchStaticProgressions returns every recorded attempt of a static sorted by time.
+func (d DatabaseHandler) FetchStaticProgressions(staticID uint) ([]StaticProgression, error) {
+	results := make([]StaticProgression, 0)
+	tx := d.Conn.Where("static_id = ?", staticID).Order("time asc").Preload("EncounterInfo").Find(&results)
+	return results, tx.Error
+}
+
+// FetchBestStaticProgressions returns the best attempt of a static for each encounter.
+func (d DatabaseHandler) FetchBestStaticProgressions(staticID uint) ([]StaticProgression, error) {
+	results := make([]StaticProgression, 0)
+	ranked := d.Conn.Model(&StaticProgression{}).
+		Select("*, ROW_NUMBER() OVER (PARTITION BY encounter_info_id ORDER BY is_kill desc, fight_percentage asc, time asc) AS prog_rank").
+		Where("static_id = ?", staticID)
+	tx := d.Conn.Table("(?) AS ranked_progressions", ranked).Where("prog_rank = 1").Preload("EncounterInfo").Find(&results)
+	return results, tx.Error
+}
+
+// ClaimStatic names a static, claimedBy is the owner key hash of the member that named it or admin.
+func (d DatabaseHandler) ClaimStatic(static *Static, name string, claimedBy string) error {
+	static.Name = name
+	static.ClaimedBy = claimedBy
+	static.ClaimedAt = time.Now()
+	return d.Conn.Omit(clause.Associations).Save(static).Error
+}
+
+// UnclaimStatic removes the name of a static so it can be claimed again.
+func (d DatabaseHandler) UnclaimStatic(static *Static) error {
+	static.Name = ""
+	static.ClaimedBy = ""
+	static.ClaimedAt = time.Time{}
+	return d.Conn.Omit(clause.Associations).Save(static).Error
+}
+
+// IsStaticMemberOwner returns true if the owner has verified any member of the static.
+func (d DatabaseHandler) IsStaticMemberOwner(staticID uint, ownerKeyHash string) (bool, error) {
+	var count int64
+	tx := d.Conn.Model(&CharacterClaim{}).
+		Joins("JOIN static_members ON static_members.character_id = character_claims.character_id").
+		Where("static_members.static_id = ? AND character_claims.owner_key_hash = ? AND character_claims.verified_at IS NOT NULL", staticID, ownerKeyHash).
+		Count(&count)
+	return count > 0, tx.Error
+}
+
 func (d DatabaseHandler) HasFFLogsReport(reportID string) bool {
 	var count int64
+	d.Conn.Model(&Report{}).Where("report_id = ?", reportID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	// reports imported before the reports table existed
 	d.Conn.Model(&CharacterProgression{}).Where("report_id = ?", reportID).Count(&count)
 	return count > 0
 }
 
+func (d DatabaseHandler) FetchImportJobFromReportID(reportID string) (ImportJob, error) {
+	importJob := ImportJob{}
+	tx := d.Conn.First(&importJob, "report_id = ?", reportID)
+	return importJob, tx.Error
+}
+
+func (d DatabaseHandler) FetchPendingImportJobs() ([]ImportJob, error) {
+	results := make([]ImportJob, 0)
+	tx := d.Conn.Where("status IN ?", []string{ImportStatusQueued, ImportStatusFetching, ImportStatusWriting, ImportStatusRetrying}).Order("enqueued_at asc").Find(&results)
+	return results, tx.Error
+}
+
+// FetchRecentImportJobs returns the most recently enqueued import jobs that are no longer pending.
+func (d DatabaseHandler) FetchRecentImportJobs(limit int) ([]ImportJob, error) {
+	results := make([]ImportJob, 0)
+	tx := d.Conn.Where("status NOT IN ?", []string{ImportStatusQueued, ImportStatusFetching, ImportStatusWriting, ImportStatusRetrying}).Order("enqueued_at desc").Limit(limit).Find(&results)
+	return results, tx.Error
+}
+
+func (d DatabaseHandler) SaveImportJob(importJob *ImportJob) error {
+	return d.Conn.Save(importJob).Error
+}
+
+// resolveCharacter finds the character record for a name and server hash and fflogs game id.
+// Records created before the game id was known are merged in to the record that has it.
+func (d DatabaseHandler) resolveCharacter(compareHash string, gameID int64) (Character, error) {
+	character, err := d.FetchCharacterFromCompareHash(compareHash)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return character, err
+	}
+	if err == nil {
+		if character, err = d.FetchCanonicalCharacter(character); err != nil {
+			return character, err
+		}
+	}
+	if gameID <= 0 || character.GameID == gameID {
+		return character, err
+	}
+	gameCharacter, gameErr := d.FetchCharacterFromGameID(gameID)
+	if gameErr == gorm.ErrRecordNotFound {
+		// name and server now belong to a different character
+		if character.GameID != 0 {
+			return Character{CompareHash: fmt.Sprintf("%s-%d", compareHash, gameID)}, gorm.ErrRecordNotFound
+		}
+		return character, err
+	}
+	if gameErr != nil {
+		return character, gameErr
+	}
+	if gameCharacter, gameErr = d.FetchCanonicalCharacter(gameCharacter); gameErr != nil {
+		return character, gameErr
+	}
+	if err == nil && character.GameID == 0 && character.ID != gameCharacter.ID {
+		if err := d.MergeCharacters(character, gameCharacter); err != nil {
+			return character, err
+		}
+	}
+	return gameCharacter, nil
+}
+
+func (d DatabaseHandler) syncCharacterAlias(characterID uint, alias Character, lastSeen time.Time) error {
+	characterAlias := CharacterAlias{}
+	if tx := d.Conn.Where("compare_hash = ? AND character_id = ?", alias.CompareHash, characterID).Find(&characterAlias); tx.Error != nil {
+		return tx.Error
+	}
+	characterAlias.CharacterID = characterID
+	characterAlias.CompareHash = alias.CompareHash
+	characterAlias.Name = alias.Name
+	characterAlias.Server = alias.Server
+	if lastSeen.After(characterAlias.LastSeen) {
+		characterAlias.LastSeen = lastSeen
+	}
+	return d.Conn.Save(&characterAlias).Error
+}
+
 func (d DatabaseHandler) syncCharacterFromFFLogCharacterReport(characterReport *FFLogCharacterReport) error {
-	character, err := d.FetchCharacterFromCompareHash(characterReport.Character.CompareHash)
+	character, err := d.resolveCharacter(characterReport.Character.CompareHash, characterReport.Character.GameID)
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
+	lastSeen := characterReport.LastSeen()
 	if character.UID == "" {
 		// generate uid, ensure no collision
 		character.UID = GenerateUID()
@@ -160,12 +1287,27 @@ func (d DatabaseHandler) syncCharacterFromFFLogCharacterReport(characterReport *
 			character.UID = GenerateUID()
 		}
 	}
-	character.CompareHash = characterReport.Character.CompareHash
-	character.Name = characterReport.Character.Name
-	character.Server = characterReport.Character.Server
+	if character.CompareHash == "" {
+		character.CompareHash = characterReport.Character.CompareHash
+	}
+	if character.GameID == 0 {
+		character.GameID = characterReport.Character.GameID
+	}
+	// display the most recently seen name and server
+	latestAliases, err := d.FetchCharacterAliases(character.ID)
+	if err != nil {
+		return err
+	}
+	if character.ID == 0 || len(latestAliases) == 0 || !lastSeen.Before(latestAliases[0].LastSeen) {
+		character.Name = characterReport.Character.Name
+		character.Server = characterReport.Character.Server
+	}
 	if tx := d.Conn.Save(&character); tx.Error != nil {
 		return tx.Error
 	}
+	if err := d.syncCharacterAlias(character.ID, characterReport.Character, lastSeen); err != nil {
+		return err
+	}
 	characterReport.Character = character
 	return nil
 }
@@ -189,20 +1331,21 @@ func (d DatabaseHandler) syncEncounterInfoFromFFLogCharacterReport(characterRepo
 	return nil
 }
 
-func (d DatabaseHandler) syncCharacterProgressionsFromFFLogCharacterReport(characterReport *FFLogCharacterReport) error {
+func (d DatabaseHandler) syncCharacterProgressionsFromFFLogCharacterReport(characterReport *FFLogCharacterReport) (int, error) {
+	count := 0
 	for i, characterProgression := range characterReport.Progression {
-		// ensure actual progress was made
-		bestCharacterProgressionDB, err := d.FetchBestCharacterProgressionForEncounter(characterReport.Character.ID, characterProgression.EncounterInfo.ID)
+		// ensure actual progress was made on this job
+		bestCharacterProgressionDB, err := d.FetchBestCharacterProgressionForEncounterJob(characterReport.Character.ID, characterProgression.EncounterInfo.ID, characterProgression.Job)
 		if err != nil && err != gorm.ErrRecordNotFound {
-			return err
+			return count, err
 		}
 		if err != gorm.ErrRecordNotFound && !bestCharacterProgressionDB.IsImprovement(characterProgression) {
 			continue
 		}
 		// determine if this report needs update
-		characterProgressionDB, err := d.FetchCharacterProgressionFromReportID(characterReport.ReportID, characterReport.Character.ID, characterProgression.EncounterInfo.ID)
+		characterProgressionDB, err := d.FetchCharacterProgressionFromReportID(characterReport.ReportID, characterReport.Character.ID, characterProgression.EncounterInfo.ID, characterProgression.Job)
 		if err != nil && err != gorm.ErrRecordNotFound {
-			return err
+			return count, err
 		}
 		characterProgressionDB.ReportID = characterReport.ReportID
 		characterProgressionDB.CharacterID = characterReport.Character.ID
@@ -218,28 +1361,257 @@ func (d DatabaseHandler) syncCharacterProgressionsFromFFLogCharacterReport(chara
 		characterProgressionDB.Duration = characterProgression.Duration
 		characterProgressionDB.Job = characterProgression.Job
 		if tx := d.Conn.Save(&characterProgressionDB); tx.Error != nil {
-			return tx.Error
+			return count, tx.Error
 		}
 		characterReport.Progression[i] = characterProgressionDB
+		count++
 	}
-	return nil
+	return count, nil
 }
 
-func (d DatabaseHandler) HandleFFLogCharacterReport(characterReport FFLogCharacterReport) error {
-	if err := d.syncCharacterFromFFLogCharacterReport(&characterReport); err != nil {
-		return err
+func (d DatabaseHandler) syncCharacterPullsFromFFLogCharacterReport(characterReport *FFLogCharacterReport) error {
+	if len(characterReport.Pulls) == 0 {
+		return nil
 	}
-	if err := d.syncEncounterInfoFromFFLogCharacterReport(&characterReport); err != nil {
-		return err
+	// encounter info was synced with the progressions
+	encounterInfos := make(map[string]EncounterInfo)
+	for _, characterProgression := range characterReport.Progression {
+		encounterInfos[characterProgression.EncounterInfo.CompareHash] = characterProgression.EncounterInfo
+	}
+	characterPulls := make([]CharacterPull, 0, len(characterReport.Pulls))
+	for _, characterPull := range characterReport.Pulls {
+		encounterInfo, ok := encounterInfos[characterPull.EncounterInfo.CompareHash]
+		if !ok {
+			continue
+		}
+		characterPull.CharacterID = characterReport.Character.ID
+		characterPull.EncounterInfoID = encounterInfo.ID
+		characterPull.EncounterInfo = EncounterInfo{}
+		characterPulls = append(characterPulls, characterPull)
+	}
+	// pulls are replaced when a report is refreshed
+	return d.Conn.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "character_id"}, {Name: "report_id"}, {Name: "fight_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "encounter_info_id", "start_time", "end_time", "duration", "fight_percentage", "phase", "phase_percentage", "is_kill", "job"}),
+	}).Create(&characterPulls).Error
+}
+
+// HandleFFLogCharacterReport saves a character report and returns the number of progressions written.
+// The report's character is replaced with the saved character record.
+func (d DatabaseHandler) HandleFFLogCharacterReport(characterReport *FFLogCharacterReport) (int, error) {
+	if err := d.syncCharacterFromFFLogCharacterReport(characterReport); err != nil {
+		return 0, err
+	}
+	if err := d.syncEncounterInfoFromFFLogCharacterReport(characterReport); err != nil {
+		return 0, err
+	}
+	if d.storePulls {
+		if err := d.syncCharacterPullsFromFFLogCharacterReport(characterReport); err != nil {
+			return 0, err
+		}
+	}
+	return d.syncCharacterProgressionsFromFFLogCharacterReport(characterReport)
+}
+
+// syncStaticFromFFLogPartyReport saves a party report, members are the report's saved characters keyed by their hash in the report.
+func (d DatabaseHandler) syncStaticFromFFLogPartyReport(partyReport *FFLogPartyReport, memberCharacters map[string]Character) error {
+	members := make([]Character, 0, len(partyReport.MemberHashes))
+	for _, memberHash := range partyReport.MemberHashes {
+		// the hash alone can belong to a different character with the same name and server
+		character, ok := memberCharacters[memberHash]
+		if !ok {
+			var err error
+			if character, err = d.FetchCharacterFromCompareHash(memberHash); err != nil {
+				return err
+			}
+			if character, err = d.FetchCanonicalCharacter(character); err != nil {
+				return err
+			}
+		}
+		members = append(members, character)
 	}
-	if err := d.syncCharacterProgressionsFromFFLogCharacterReport(&characterReport); err != nil {
+	static, err := d.FetchStaticFromCompareHash(partyReport.CompareHash)
+	if err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
-	return nil
+	if static.UID == "" {
+		// generate uid, ensure no collision
+		static.UID = GenerateUID()
+		for {
+			_, err = d.FetchStaticFromUID(static.UID)
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					break
+				}
+				return err
+			}
+			static.UID = GenerateUID()
+		}
+		static.CompareHash = partyReport.CompareHash
+		if tx := d.Conn.Save(&static); tx.Error != nil {
+			return tx.Error
+		}
+		if err := d.Conn.Model(&static).Association("Members").Replace(members); err != nil {
+			return err
+		}
+	}
+	// every report is kept to build the static's timeline
+	for _, partyProgression := range partyReport.Progression {
+		encounterInfo, err := d.FetchEncounterInfoFromCompareHash(partyProgression.EncounterInfo.CompareHash)
+		if err != nil {
+			return err
+		}
+		staticProgression := StaticProgression{}
+		tx := d.Conn.Where("static_id = ? AND report_id = ? AND encounter_info_id = ?", static.ID, partyReport.ReportID, encounterInfo.ID).Find(&staticProgression)
+		if tx.Error != nil {
+			return tx.Error
+		}
+		staticProgression.StaticID = static.ID
+		staticProgression.ReportID = partyReport.ReportID
+		staticProgression.EncounterInfoID = encounterInfo.ID
+		staticProgression.Time = partyProgression.Time
+		staticProgression.FightPercentage = partyProgression.FightPercentage
+		staticProgression.Phase = partyProgression.Phase
+		staticProgression.PhasePercentage = partyProgression.PhasePercentage
+		staticProgression.Duration = partyProgression.Duration
+		staticProgression.IsKill = partyProgression.IsKill
+		staticProgression.Pulls = partyReport.Pulls[encounterInfo.CompareHash]
+		if tx := d.Conn.Save(&staticProgression); tx.Error != nil {
+			return tx.Error
+		}
+	}
+	var reportCount int64
+	if tx := d.Conn.Model(&StaticProgression{}).Where("static_id = ?", static.ID).Distinct("report_id").Count(&reportCount); tx.Error != nil {
+		return tx.Error
+	}
+	static.ReportCount = int(reportCount)
+	return d.Conn.Omit(clause.Associations).Save(&static).Error
+}
+
+// HandleFFLogReport saves every character report from an FFLogs report and records the report as imported in a single transaction.
+func (d DatabaseHandler) HandleFFLogReport(fflReport FFLogReport) (Report, error) {
+	report := Report{}
+	err := d.Conn.Transaction(func(tx *gorm.DB) error {
+		txd := d
+		txd.Conn = tx
+		var err error
+		report, err = txd.FetchReportFromReportID(fflReport.ReportID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		report.ReportID = fflReport.ReportID
+		report.Title = fflReport.Title
+		report.Owner = fflReport.Owner
+		report.ZoneID = fflReport.ZoneID
+		report.StartTime = fflReport.StartTime
+		report.EndTime = fflReport.EndTime
+		report.GameVersion = fflReport.GameVersion
+		report.ImportedAt = time.Now()
+		report.ProgressionCount = 0
+		// opted out characters are left out of the report entirely
+		optedOutHashes := make(map[string]bool)
+		// a character that swapped jobs has a character report for each job
+		characterIDs := make(map[uint]bool)
+		memberCharacters := make(map[string]Character)
+		for _, characterReport := range fflReport.Characters {
+			optedOut, err := txd.IsCharacterOptedOut(characterReport.Character)
+			if err != nil {
+				return err
+			}
+			if optedOut {
+				optedOutHashes[characterReport.Character.CompareHash] = true
+				continue
+			}
+			compareHash := characterReport.Character.CompareHash
+			progressionCount, err := txd.HandleFFLogCharacterReport(&characterReport)
+			if err != nil {
+				return err
+			}
+			memberCharacters[compareHash] = characterReport.Character
+			characterIDs[characterReport.Character.ID] = true
+			report.ProgressionCount += progressionCount
+		}
+		report.CharacterCount = len(characterIDs)
+		for _, partyReport := range fflReport.Parties {
+			if partyReport.HasMember(optedOutHashes) {
+				continue
+			}
+			if err := txd.syncStaticFromFFLogPartyReport(&partyReport, memberCharacters); err != nil {
+				return err
+			}
+		}
+		report.Outcome = ReportOutcomeImported
+		if report.CharacterCount == 0 {
+			report.Outcome = ReportOutcomeNoEncounters
+		} else if report.ProgressionCount == 0 {
+			report.Outcome = ReportOutcomeNoImprovements
+		}
+		return tx.Save(&report).Error
+	})
+	return report, err
+}
+
+// EachVisibleCharacter calls fn for every character that is not merged or hidden, loading them in batches.
+func (d DatabaseHandler) EachVisibleCharacter(fn func(character Character) error) error {
+	characters := make([]Character, 0)
+	return d.Conn.Where("merged_into_id IS NULL AND NOT hidden").FindInBatches(&characters, 500, func(tx *gorm.DB, batch int) error {
+		for _, character := range characters {
+			if err := fn(character); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// DatabaseStats contains row counts used to give an overview of the database.
+type DatabaseStats struct {
+	Characters       int64
+	HiddenCharacters int64
+	MergedCharacters int64
+	Reports          int64
+	Progressions     int64
+	Pulls            int64
+	Statics          int64
+	PendingImports   int64
+	FailedImports    int64
+}
+
+func (d DatabaseHandler) FetchDatabaseStats() (DatabaseStats, error) {
+	out := DatabaseStats{}
+	counts := []struct {
+		model interface{}
+		query string
+		args  []interface{}
+		count *int64
+	}{
+		{&Character{}, "merged_into_id IS NULL AND NOT hidden", nil, &out.Characters},
+		{&Character{}, "merged_into_id IS NULL AND hidden", nil, &out.HiddenCharacters},
+		{&Character{}, "merged_into_id IS NOT NULL", nil, &out.MergedCharacters},
+		{&Report{}, "", nil, &out.Reports},
+		{&CharacterProgression{}, "", nil, &out.Progressions},
+		{&CharacterPull{}, "", nil, &out.Pulls},
+		{&Static{}, "", nil, &out.Statics},
+		{&ImportJob{}, "status IN ?", []interface{}{[]string{ImportStatusQueued, ImportStatusFetching, ImportStatusWriting, ImportStatusRetrying}}, &out.PendingImports},
+		{&ImportJob{}, "status = ?", []interface{}{ImportStatusFailed}, &out.FailedImports},
+	}
+	for _, count := range counts {
+		tx := d.Conn.Model(count.model)
+		if count.query != "" {
+			tx = tx.Where(count.query, count.args...)
+		}
+		if err := tx.Count(count.count).Error; err != nil {
+			return out, err
+		}
+	}
+	return out, nil
 }
 
 func (d DatabaseHandler) FindCharacters(name string) ([]Character, error) {
 	characters := make([]Character, 0)
-	tx := d.Conn.Where("name LIKE ?", fmt.Sprintf("%%%s%%", name)).Find(&characters)
+	pattern := fmt.Sprintf("%%%s%%", name)
+	// previous names are searched too
+	aliasCharacterIDs := d.Conn.Model(&CharacterAlias{}).Select("character_id").Where("name LIKE ?", pattern)
+	tx := d.Conn.Where("merged_into_id IS NULL AND NOT hidden AND (name LIKE ? OR id IN (?))", pattern, aliasCharacterIDs).Find(&characters)
 	return characters, tx.Error
 }
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// verifyTestCharacter records a verified claim on a character for the given owner key hash.
+func verifyTestCharacter(t *testing.T, db *DatabaseHandler, character Character, ownerKeyHash string) {
+	t.Helper()
+	characterClaim := CharacterClaim{CharacterID: character.ID, OwnerKeyHash: ownerKeyHash, Token: claimTokenPrefix + "test"}
+	if err := db.SaveCharacterClaim(&characterClaim); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.VerifyCharacterClaim(&characterClaim, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsStaticMemberOwner(t *testing.T) {
+	db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	importTestReport(t, db, fflogHandler, "FakeNonStdComp11")
+	statics := make([]Static, 0)
+	if err := db.Conn.Preload("Members").Order("id asc").Find(&statics).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(statics) != 2 {
+		t.Fatalf("%d statics saved, want 2", len(statics))
+	}
+	// hollis crane only played in the first static
+	character, err := db.FetchCharacterFromGameID(270620503)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyTestCharacter(t, db, character, "owner")
+	for _, tt := range []struct {
+		StaticID     uint
+		OwnerKeyHash string
+		Want         bool
+	}{
+		{statics[0].ID, "owner", true},
+		{statics[1].ID, "owner", false},
+		{statics[0].ID, "someone else", false},
+	} {
+		got, err := db.IsStaticMemberOwner(tt.StaticID, tt.OwnerKeyHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.Want {
+			t.Errorf("static %d owner %s = %t, want %t", tt.StaticID, tt.OwnerKeyHash, got, tt.Want)
+		}
+	}
+	if err := db.ClaimStatic(&statics[0], "Fixture Static", "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UnclaimStatic(&statics[0]); err != nil {
+		t.Fatal(err)
+	}
+	static, err := db.FetchStaticFromUID(statics[0].UID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if static.IsClaimed() || static.ClaimedBy != "" || len(static.Members) != staticPartySize {
+		t.Errorf("unclaimed static = %q claimed by %q with %d members", static.Name, static.ClaimedBy, len(static.Members))
+	}
+}
+
+func TestEncounterDistributionLeavesOutHidden(t *testing.T) {
+	db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	hiddenCharacter, err := db.FetchCharacterFromNameServer("Aria Vale", "Gilgamesh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetCharacterHidden(hiddenCharacter, true); err != nil {
+		t.Fatal(err)
+	}
+	characterProgressions, err := db.FetchBestCharacterProgressions(hiddenCharacter.ID)
+	if err != nil || len(characterProgressions) != 1 {
+		t.Fatalf("fetch progressions: %v, %d found", err, len(characterProgressions))
+	}
+	encounterID := characterProgressions[0].EncounterInfoID
+	character, err := db.FetchCharacterFromNameServer("Bram Tolliver", "Gilgamesh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetCharacterEncounterHidden(character.ID, encounterID, true); err != nil {
+		t.Fatal(err)
+	}
+	distribution, err := db.FetchEncounterDistribution(encounterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distribution.Characters != 6 || distribution.Clears != 6 {
+		t.Errorf("distribution has %d characters and %d clears, want 6 and 6", distribution.Characters, distribution.Clears)
+	}
+	if len(distribution.ClearsOverTime) != 1 || distribution.ClearsOverTime[0].Total != 6 {
+		t.Errorf("clears over time = %+v, want 6 clears on one day", distribution.ClearsOverTime)
+	}
+	leaderboard, err := db.FetchLeaderboard(encounterID, LeaderboardEarliestClear, nil, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(leaderboard)) != distribution.Clears {
+		t.Errorf("leaderboard has %d entries, distribution has %d clears", len(leaderboard), distribution.Clears)
+	}
+}
+
+func TestDeleteReportRebuildsOvershadowedProgressions(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Reports []string
+		// StorePulls is whether pulls are stored when importing each report
+		StorePulls []bool
+		Err        error
+		Rebuilt    int64
+	}{
+		{"stored pulls", []string{"FakeKillReport11", "FakeEchoReport11"}, []bool{true, true}, nil, 8},
+		{"no stored pulls", []string{"FakeKillReport11", "FakeEchoReport11"}, []bool{false, false}, ErrProgressionsNotRebuilt, 0},
+		{"no other reports", []string{"FakeKillReport11"}, []bool{false}, nil, 0},
+		{"older report without pulls", []string{"FakeKillReport11", "FakeEchoReport11"}, []bool{true, false}, ErrProgressionsNotRebuilt, 0},
+	} {
+		db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+		for i, reportID := range tt.Reports {
+			db.storePulls = tt.StorePulls[i]
+			report := importTestReport(t, db, fflogHandler, reportID)
+			// the echo wipes are overshadowed by the kill and only recorded as pulls
+			if i > 0 && report.Outcome != ReportOutcomeNoImprovements {
+				t.Fatalf("%s: %s outcome = %s, want %s", tt.Name, reportID, report.Outcome, ReportOutcomeNoImprovements)
+			}
+		}
+		if err := db.DeleteReport(tt.Reports[0]); err != tt.Err {
+			t.Errorf("%s: err = %v, want %v", tt.Name, err, tt.Err)
+		}
+		if db.HasFFLogsReport(tt.Reports[0]) {
+			t.Errorf("%s: report was not deleted", tt.Name)
+		}
+		var count int64
+		if err := db.Conn.Model(&CharacterProgression{}).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.Rebuilt {
+			t.Errorf("%s: %d progressions rebuilt, want %d", tt.Name, count, tt.Rebuilt)
+		}
+	}
+}
+
+func TestMergeCharactersClaims(t *testing.T) {
+	db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	characters := make([]Character, 0)
+	if err := db.Conn.Order("id asc").Find(&characters).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		Name           string
+		Source         Character
+		Target         Character
+		TargetVerified bool
+		WantOwner      string
+	}{
+		{"unverified target", characters[0], characters[1], false, "source owner"},
+		{"verified target", characters[2], characters[3], true, "target owner"},
+	} {
+		verifyTestCharacter(t, db, tt.Source, "source owner")
+		if tt.TargetVerified {
+			verifyTestCharacter(t, db, tt.Target, "target owner")
+		} else if err := db.SaveCharacterClaim(&CharacterClaim{CharacterID: tt.Target.ID, OwnerKeyHash: "pending owner", Token: claimTokenPrefix + "test"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.MergeCharacters(tt.Source, tt.Target); err != nil {
+			t.Fatal(err)
+		}
+		var claims int64
+		if err := db.Conn.Model(&CharacterClaim{}).Where("character_id IN ?", []uint{tt.Source.ID, tt.Target.ID}).Count(&claims).Error; err != nil {
+			t.Fatal(err)
+		}
+		if claims != 1 {
+			t.Errorf("%s: %d claims left, want 1", tt.Name, claims)
+		}
+		characterClaim, err := db.FetchVerifiedCharacterClaim(tt.Target.ID)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.Name, err)
+		}
+		if characterClaim.OwnerKeyHash != tt.WantOwner {
+			t.Errorf("%s: target is owned by %s, want %s", tt.Name, characterClaim.OwnerKeyHash, tt.WantOwner)
+		}
+	}
+}
+
+func TestNameCollisionKeepsAliases(t *testing.T) {
+	// a copy of the kill report where a different aria vale @ gilgamesh plays with a new member
+	fixtureDir := t.TempDir()
+	rawReport, err := os.ReadFile(filepath.Join(fakeFFLogsFixtureDir, "FakeKillReport11.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fixtureDir, "FakeKillReport11.json"), rawReport, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fixture := map[string]interface{}{}
+	if err := json.Unmarshal(rawReport, &fixture); err != nil {
+		t.Fatal(err)
+	}
+	friendlies := fixture["friendlies"].([]interface{})
+	friendlies[0].(map[string]interface{})["guid"] = 270999001
+	friendlies[1].(map[string]interface{})["name"] = "Corin Ashdown"
+	if rawReport, err = json.Marshal(fixture); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fixtureDir, "FakeNameClash111.json"), rawReport, 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(NewFakeFFLogsHandler(fixtureDir))
+	t.Cleanup(server.Close)
+	config := newTestConfig(t)
+	config.FFLogsBaseURL = server.URL
+	db, fflogHandler := newTestHandlers(t, config)
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	importTestReport(t, db, fflogHandler, "FakeNameClash111")
+
+	original, err := db.FetchCharacterFromGameID(270886838)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namesake, err := db.FetchCharacterFromGameID(270999001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.ID == namesake.ID {
+		t.Fatalf("characters with different game ids share record %d", original.ID)
+	}
+	for _, character := range []Character{original, namesake} {
+		characterAliases, err := db.FetchCharacterAliases(character.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(characterAliases) != 1 || characterAliases[0].Name != "Aria Vale" || characterAliases[0].Server != "Gilgamesh" {
+			t.Errorf("character %d aliases = %+v, want only Aria Vale @ Gilgamesh", character.GameID, characterAliases)
+		}
+	}
+	static := Static{}
+	if err := db.Conn.Preload("Members").Order("id desc").First(&static).Error; err != nil {
+		t.Fatal(err)
+	}
+	memberIDs := make(map[uint]bool)
+	for _, member := range static.Members {
+		memberIDs[member.ID] = true
+	}
+	if !memberIDs[namesake.ID] || memberIDs[original.ID] {
+		t.Errorf("second static members %v, want character %d and not %d", memberIDs, namesake.ID, original.ID)
+	}
+}
@@ -3,7 +3,19 @@ package main
 import "errors"
 
 var (
-	ErrReportAlreadyImported = errors.New("report already imported")
-	ErrAlreadyInQueue        = errors.New("report is already in queue")
-	ErrInvalidClient         = errors.New("invalid client detected")
+	ErrReportAlreadyImported      = errors.New("report already imported")
+	ErrAlreadyInQueue             = errors.New("report is already in queue")
+	ErrNotInQueue                 = errors.New("report is not in queue")
+	ErrImportJobBusy              = errors.New("report is being processed")
+	ErrInvalidClient              = errors.New("invalid client detected")
+	ErrUnsupportedByFFLogsAPI     = errors.New("not supported by the configured fflogs api version")
+	ErrUnknownDataCenter          = errors.New("unknown data center")
+	ErrUnknownRegion              = errors.New("unknown region")
+	ErrUnknownWorld               = errors.New("unknown world")
+	ErrMergeSameCharacter         = errors.New("cannot merge a character with itself")
+	ErrLodestoneCharacterNotFound = errors.New("lodestone character not found")
+	ErrLodestoneCharacterMismatch = errors.New("lodestone character does not match")
+	ErrLodestoneTokenNotFound     = errors.New("verification token not found in lodestone bio")
+	ErrCharacterNotOwned          = errors.New("character is not verified by you")
+	ErrProgressionsNotRebuilt     = errors.New("progressions overshadowed by the report may not be rebuilt as other reports were imported without stored pulls")
 )
@@ -1,24 +1,162 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/RyuaNerin/go-fflogs"
+	"github.com/RyuaNerin/go-fflogs/structure"
+	"golang.org/x/time/rate"
 )
 
-type FFLogsHandler struct {
+const fflogsRequestTimeout = time.Second * 30
+
+const (
+	FFLogsAPIVersion1 = "v1"
+	FFLogsAPIVersion2 = "v2"
+)
+
+// FFLogsStatusError is returned when FFLogs responds with an unsuccessful status code.
+type FFLogsStatusError struct {
+	StatusCode int
+}
+
+func (e *FFLogsStatusError) Error() string {
+	return fmt.Sprintf("fflogs responded with status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// fflogsStatusTransport turns unsuccessful responses in to errors as the fflogs client ignores status codes.
+type fflogsStatusTransport struct {
+	base http.RoundTripper
+}
+
+func (t fflogsStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, &FFLogsStatusError{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// IsFFLogsErrorTransient returns true if a failed request to FFLogs may succeed if tried again later.
+func IsFFLogsErrorTransient(err error) bool {
+	var statusErr *FFLogsStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// FFLogsReportSource fetches report data from an FFLogs API.
+type FFLogsReportSource interface {
+	// FetchReportFights returns the fights and friendlies of a report in the v1 api format.
+	FetchReportFights(ctx context.Context, reportID string) (*structure.Fights, error)
+	// FetchGuildReportCodes returns the codes of reports uploaded for a guild since the given time.
+	FetchGuildReportCodes(ctx context.Context, guild DiscoveryGuild, since time.Time) ([]string, error)
+	// FetchCharacterReportCodes returns the codes of the most recent reports a character appears in.
+	FetchCharacterReportCodes(ctx context.Context, character Character) ([]string, error)
+}
+
+// fflogsV1ReportSource fetches reports from the v1 REST api.
+type fflogsV1ReportSource struct {
 	client *fflogs.Client
 }
 
-func NewFFLogsHandler(config *Config) (*FFLogsHandler, error) {
+func newFFLogsV1ReportSource(config *Config, httpClient *http.Client) (*fflogsV1ReportSource, error) {
 	opts := fflogs.ClientOpt{
-		ApiKey: config.FFLogsApiKey,
+		ApiKey:     config.FFLogsApiKey,
+		HttpClient: httpClient,
+		BaseUrl:    config.FFLogsBaseURL + "/v1",
 	}
-
 	client, err := fflogs.NewClient(&opts)
 	if err != nil {
 		return nil, err
 	}
+	return &fflogsV1ReportSource{client: client}, nil
+}
+
+func (s fflogsV1ReportSource) FetchReportFights(ctx context.Context, reportID string) (*structure.Fights, error) {
+	reportOpts := fflogs.ReportFightsOptions{
+		Code: reportID,
+	}
+	return s.client.ReportFights(ctx, &reportOpts)
+}
+
+func (s fflogsV1ReportSource) FetchGuildReportCodes(ctx context.Context, guild DiscoveryGuild, since time.Time) ([]string, error) {
+	start := int(since.UnixMilli())
+	reportOpts := fflogs.ReportsGuildOptions{
+		GuildName:    guild.Name,
+		ServerName:   guild.Server,
+		ServerRegion: fflogs.Region(strings.ToUpper(guild.Region)),
+		Start:        &start,
+	}
+	reports, err := s.client.ReportsGuild(ctx, &reportOpts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(reports))
+	for _, report := range reports {
+		out = append(out, report.ID)
+	}
+	return out, nil
+}
+
+func (s fflogsV1ReportSource) FetchCharacterReportCodes(ctx context.Context, character Character) ([]string, error) {
+	return nil, ErrUnsupportedByFFLogsAPI
+}
+
+type FFLogsHandler struct {
+	source FFLogsReportSource
+	// limiter is shared by every request made through the handler to stay within the api quota
+	limiter *rate.Limiter
+}
+
+func NewFFLogsHandler(config *Config) (*FFLogsHandler, error) {
+	httpClient := &http.Client{
+		Timeout:   fflogsRequestTimeout,
+		Transport: fflogsStatusTransport{base: http.DefaultTransport},
+	}
+
+	var source FFLogsReportSource
+	var err error
+	switch config.FFLogsAPIVersion {
+	case FFLogsAPIVersion1:
+		source, err = newFFLogsV1ReportSource(config, httpClient)
+	case FFLogsAPIVersion2:
+		source, err = newFFLogsV2ReportSource(config, httpClient)
+	default:
+		err = fmt.Errorf("unknown fflogs api version %s", config.FFLogsAPIVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	return &FFLogsHandler{
-		client: client,
+		source:  source,
+		limiter: rate.NewLimiter(rate.Limit(config.FFLogsRateLimit/60), 1),
 	}, nil
 }
+
+func (ffl FFLogsHandler) FetchGuildReportCodes(guild DiscoveryGuild, since time.Time) ([]string, error) {
+	if err := ffl.limiter.Wait(context.Background()); err != nil {
+		return nil, err
+	}
+	return ffl.source.FetchGuildReportCodes(context.Background(), guild, since)
+}
+
+func (ffl FFLogsHandler) FetchCharacterReportCodes(character Character) ([]string, error) {
+	if err := ffl.limiter.Wait(context.Background()); err != nil {
+		return nil, err
+	}
+	return ffl.source.FetchCharacterReportCodes(context.Background(), character)
+}
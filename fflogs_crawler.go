@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// discoveryLookback is how far back to look for guild reports.
+const discoveryLookback = time.Hour * 24 * 7
+
+// FFLogsReportCrawler periodically looks for new reports from configured guilds and recently active characters and adds them to the import queue.
+type FFLogsReportCrawler struct {
+	config *Config
+	db     *DatabaseHandler
+	fflog  *FFLogsHandler
+	queue  *FFLogsImportQueue
+}
+
+func NewFFLogsReportCrawler(config *Config, db *DatabaseHandler, fflog *FFLogsHandler, queue *FFLogsImportQueue) *FFLogsReportCrawler {
+	return &FFLogsReportCrawler{
+		config: config,
+		db:     db,
+		fflog:  fflog,
+		queue:  queue,
+	}
+}
+
+// enqueue adds reports that have not been imported or failed to the queue and returns the number added.
+func (c *FFLogsReportCrawler) enqueue(reportIDs []string) int {
+	count := 0
+	for _, reportID := range reportIDs {
+		if c.db.HasFFLogsReport(reportID) {
+			continue
+		}
+		// reports that can never be imported or ran out of attempts are only retried when submitted by hand
+		if importJob, err := c.db.FetchImportJobFromReportID(reportID); err == nil && importJob.Status == ImportStatusFailed {
+			continue
+		}
+		if err := c.queue.Add(reportID, "discovery"); err != nil {
+			if err != ErrAlreadyInQueue {
+				log.Printf("Error adding discovered FFLogs report %s: %s\n", reportID, err.Error())
+			}
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+func (c *FFLogsReportCrawler) crawl() {
+	count := 0
+	for _, guild := range c.config.DiscoveryGuilds {
+		if guild.Region == "" {
+			guild.Region = GetServerRegion(guild.Server)
+			if guild.Region == "" {
+				log.Printf("Error fetching reports for guild %s @ %s: %s\n", guild.Name, guild.Server, ErrUnknownWorld.Error())
+				continue
+			}
+		}
+		reportIDs, err := c.fflog.FetchGuildReportCodes(guild, time.Now().Add(-discoveryLookback))
+		if err != nil {
+			log.Printf("Error fetching reports for guild %s @ %s: %s\n", guild.Name, guild.Server, importErrorMessage(err))
+			continue
+		}
+		count += c.enqueue(reportIDs)
+	}
+	if c.config.DiscoveryCharacters > 0 {
+		characters, err := c.db.FetchRecentlyUpdatedCharacters(c.config.DiscoveryCharacters)
+		if err != nil {
+			log.Printf("Error fetching characters for report discovery: %s\n", err.Error())
+		}
+		for _, character := range characters {
+			reportIDs, err := c.fflog.FetchCharacterReportCodes(character)
+			if err == ErrUnsupportedByFFLogsAPI {
+				break
+			}
+			if err != nil {
+				log.Printf("Error fetching reports for character %s @ %s: %s\n", character.Name, character.Server, importErrorMessage(err))
+				continue
+			}
+			count += c.enqueue(reportIDs)
+		}
+	}
+	log.Printf("Discovered %d new FFLogs report(s).\n", count)
+}
+
+// Start begins checking for new reports at the configured interval, does nothing when discovery is disabled.
+func (c *FFLogsReportCrawler) Start() {
+	if c.config.DiscoveryInterval <= 0 {
+		return
+	}
+	log.Printf("Starting FFLogs report discovery every %d minute(s).\n", c.config.DiscoveryInterval)
+	go func() {
+		c.crawl()
+		for range time.Tick(time.Duration(c.config.DiscoveryInterval) * time.Minute) {
+			c.crawl()
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestCrawlerEnqueue(t *testing.T) {
+	config := newTestConfig(t)
+	db, fflogHandler := newTestHandlers(t, config)
+	queue, err := NewFFLogsImportQueue(config, db, fflogHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler := NewFFLogsReportCrawler(config, db, fflogHandler, queue)
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	if err := db.SaveImportJob(&ImportJob{ReportID: "FakeMissingRpt11", Status: ImportStatusFailed, PermanentFailure: true}); err != nil {
+		t.Fatal(err)
+	}
+	// a transient error that used up every attempt
+	if err := queue.Add("FakeRateLimited1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	queue.process(queue.claim())
+	if importJob, err := db.FetchImportJobFromReportID("FakeRateLimited1"); err != nil || importJob.Status != ImportStatusFailed {
+		t.Fatalf("rate limited job = %+v (%v), want %s", importJob, err, ImportStatusFailed)
+	}
+	reportIDs := []string{"FakeKillReport11", "FakeMissingRpt11", "FakeRateLimited1", "FakeWipeReport11"}
+	if count := crawler.enqueue(reportIDs); count != 1 {
+		t.Errorf("%d report(s) added, want 1", count)
+	}
+	queued := make(map[string]bool)
+	for _, importJob := range queue.Jobs() {
+		queued[importJob.ReportID] = true
+	}
+	for _, reportID := range reportIDs {
+		want := reportID == "FakeWipeReport11"
+		if queued[reportID] != want {
+			t.Errorf("%s queued = %t, want %t", reportID, queued[reportID], want)
+		}
+	}
+	// already queued reports are not added twice
+	if count := crawler.enqueue(reportIDs); count != 0 {
+		t.Errorf("%d report(s) added again, want 0", count)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RyuaNerin/go-fflogs/structure"
+)
+
+const fakeFFLogsFixtureDir = "data/fixtures/fflogs"
+const fakeFFLogsStatusFile = "statuses.json"
+const fakeFFLogsGuildReportsFile = "guild_reports.json"
+
+// fakeFFLogsStatus returns the simulated error status code for a report, if any.
+func fakeFFLogsStatus(fixtureDir string, code string) (int, bool) {
+	statuses := map[string]int{}
+	if rawStatuses, err := os.ReadFile(filepath.Join(fixtureDir, fakeFFLogsStatusFile)); err == nil {
+		if err := json.Unmarshal(rawStatuses, &statuses); err != nil {
+			log.Printf("Error reading fake FFLogs statuses: %s\n", err.Error())
+		}
+	}
+	status, ok := statuses[code]
+	return status, ok
+}
+
+func fakeFFLogsGuildReports(fixtureDir string) map[string]json.RawMessage {
+	guildReports := map[string]json.RawMessage{}
+	if rawGuildReports, err := os.ReadFile(filepath.Join(fixtureDir, fakeFFLogsGuildReportsFile)); err == nil {
+		if err := json.Unmarshal(rawGuildReports, &guildReports); err != nil {
+			log.Printf("Error reading fake FFLogs guild reports: %s\n", err.Error())
+		}
+	}
+	return guildReports
+}
+
+// NewFakeFFLogsHandler returns a handler that stands in for the FFLogs v1 and v2 apis by serving report fixtures.
+// The fixtures are synthetic reports written in the v1 report fights format, the v2 api serves them converted to graphql.
+// Reports are read from <code>.json in the fixture directory, statuses.json maps report codes to error status codes
+// and guild_reports.json maps "<guild>@<server>" to the guild's report list.
+func NewFakeFFLogsHandler(fixtureDir string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/report/fights/", func(w http.ResponseWriter, r *http.Request) {
+		code := strings.TrimPrefix(r.URL.Path, "/v1/report/fights/")
+		log.Printf("Fake FFLogs request for report %s.\n", code)
+		w.Header().Set("Content-Type", "application/json")
+		// simulated failures
+		if status, ok := fakeFFLogsStatus(fixtureDir, code); ok {
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"status":%d,"error":"%s"}`, status, http.StatusText(status))
+			return
+		}
+		// fflogs responds with a 400 for reports that are missing or private
+		rawReport, err := os.ReadFile(filepath.Join(fixtureDir, filepath.Base(code)+".json"))
+		if err != nil || code == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":400,"error":"This report does not exist or is private."}`)
+			return
+		}
+		w.Write(rawReport)
+	})
+	mux.HandleFunc("/v1/reports/guild/", func(w http.ResponseWriter, r *http.Request) {
+		pathes := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/reports/guild/"), "/")
+		w.Header().Set("Content-Type", "application/json")
+		if len(pathes) < 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":400,"error":"Invalid guild."}`)
+			return
+		}
+		log.Printf("Fake FFLogs request for guild %s @ %s.\n", pathes[0], pathes[1])
+		reports, ok := fakeFFLogsGuildReports(fixtureDir)[pathes[0]+"@"+pathes[1]]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":400,"error":"Invalid guild."}`)
+			return
+		}
+		w.Write(reports)
+	})
+	mux.HandleFunc(fflogsV2TokenPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"token_type":"Bearer","expires_in":31104000,"access_token":"fake"}`)
+	})
+	mux.HandleFunc(fflogsV2ClientPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		graphReq := struct {
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&graphReq); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":[{"message":"Invalid request."}]}`)
+			return
+		}
+		code, _ := graphReq.Variables["code"].(string)
+		guildName, _ := graphReq.Variables["guildName"].(string)
+		serverSlug, _ := graphReq.Variables["serverSlug"].(string)
+		switch {
+		case code != "":
+			log.Printf("Fake FFLogs v2 request for report %s.\n", code)
+			if status, ok := fakeFFLogsStatus(fixtureDir, code); ok {
+				w.WriteHeader(status)
+				fmt.Fprintf(w, `{"error":"%s"}`, http.StatusText(status))
+				return
+			}
+			fakeFFLogsV2Report(w, fixtureDir, code)
+		case guildName != "":
+			log.Printf("Fake FFLogs v2 request for guild %s @ %s.\n", guildName, serverSlug)
+			fakeFFLogsV2GuildReports(w, fixtureDir, guildName, serverSlug)
+		default:
+			// character report lists have no fixtures
+			fmt.Fprint(w, `{"data":{"characterData":{"character":null}}}`)
+		}
+	})
+	return mux
+}
+
+// fakeFFLogsV2Report writes a report fixture as the v2 api responds to the report fights query.
+func fakeFFLogsV2Report(w http.ResponseWriter, fixtureDir string, code string) {
+	fflFights := structure.Fights{}
+	rawReport, err := os.ReadFile(filepath.Join(fixtureDir, filepath.Base(code)+".json"))
+	if err == nil {
+		err = json.Unmarshal(rawReport, &fflFights)
+	}
+	if err != nil {
+		// the v2 api reports missing reports as a graphql error
+		fmt.Fprint(w, `{"errors":[{"message":"This report does not exist or is private."}],"data":{"reportData":{"report":null}}}`)
+		return
+	}
+	percentage := func(p *int64) *float64 {
+		if p == nil {
+			return nil
+		}
+		out := float64(*p) / 100
+		return &out
+	}
+	fights := make([]map[string]interface{}, 0, len(fflFights.Fights))
+	for _, fflFight := range fflFights.Fights {
+		friendlyPlayers := make([]int64, 0)
+		for _, fflFriendly := range fflFights.Friendlies {
+			if isFFLogsFriendlyInEncounter(&fflFriendly, &fflFight) {
+				friendlyPlayers = append(friendlyPlayers, fflFriendly.ID)
+			}
+		}
+		fights = append(fights, map[string]interface{}{
+			"id":                  fflFight.ID,
+			"encounterID":         fflFight.Boss,
+			"name":                fflFight.Name,
+			"startTime":           fflFight.StartTime,
+			"endTime":             fflFight.EndTime,
+			"kill":                fflFight.Kill,
+			"size":                fflFight.Size,
+			"difficulty":          fflFight.Difficulty,
+			"hasEcho":             fflFight.HasEcho,
+			"standardComposition": fflFight.StandardComposition,
+			"bossPercentage":      percentage(fflFight.BossPercentage),
+			"fightPercentage":     percentage(fflFight.FightPercentage),
+			"lastPhase":           fflFight.LastPhaseForPercentageDisplay,
+			"gameZone":            map[string]interface{}{"id": fflFight.ZoneID, "name": fflFight.ZoneName},
+			"friendlyPlayers":     friendlyPlayers,
+		})
+	}
+	actors := make([]map[string]interface{}, 0, len(fflFights.Friendlies))
+	for _, fflFriendly := range fflFights.Friendlies {
+		actors = append(actors, map[string]interface{}{
+			"id":      fflFriendly.ID,
+			"gameID":  fflFriendly.GUID,
+			"name":    fflFriendly.Name,
+			"server":  fflFriendly.Server,
+			"subType": fflFriendly.Type,
+		})
+	}
+	report := map[string]interface{}{
+		"title":      fflFights.Title,
+		"owner":      map[string]interface{}{"name": fflFights.Owner},
+		"startTime":  fflFights.Start,
+		"endTime":    fflFights.End,
+		"zone":       map[string]interface{}{"id": fflFights.Zone},
+		"masterData": map[string]interface{}{"gameVersion": fflFights.GameVersion, "actors": actors},
+		"fights":     fights,
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"reportData": map[string]interface{}{"report": report}},
+	})
+}
+
+// fakeFFLogsV2GuildReports writes a guild's report list as the v2 api responds to the guild reports query.
+func fakeFFLogsV2GuildReports(w http.ResponseWriter, fixtureDir string, guildName string, serverSlug string) {
+	for guild, rawReports := range fakeFFLogsGuildReports(fixtureDir) {
+		name, server, _ := strings.Cut(guild, "@")
+		if name != guildName || fflogsV2ServerSlug(server) != serverSlug {
+			continue
+		}
+		reports := make([]struct {
+			ID string `json:"id"`
+		}, 0)
+		if err := json.Unmarshal(rawReports, &reports); err != nil {
+			log.Printf("Error reading fake FFLogs guild reports: %s\n", err.Error())
+		}
+		codes := make([]map[string]string, 0, len(reports))
+		for _, report := range reports {
+			codes = append(codes, map[string]string{"code": report.ID})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"reportData": map[string]interface{}{"reports": map[string]interface{}{"data": codes}}},
+		})
+		return
+	}
+	fmt.Fprint(w, `{"errors":[{"message":"No guild exists for this name/server/region."}],"data":{"reportData":{"reports":null}}}`)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fixtureProgression is the expected best progression of every character in a fixture report for an encounter.
+type fixtureProgression struct {
+	IsKill                bool
+	FightPercentage       int64
+	IsStandardComposition bool
+}
+
+const (
+	fixtureP9S  = "Anabaseios: The Ninth Circle (Savage)"
+	fixtureP10S = "Anabaseios: The Tenth Circle (Savage)"
+	fixtureTOP  = "The Omega Protocol (Ultimate)"
+)
+
+var fixtureReportTests = []struct {
+	ReportID     string
+	Characters   int
+	Progressions int
+	Statics      int64
+	Best         map[string]fixtureProgression
+	// Members are name@server of characters that must have been imported
+	Members []string
+}{
+	{
+		ReportID:     "FakeKillReport11",
+		Characters:   8,
+		Progressions: 8,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP9S: {true, 0, true}},
+		Members:      []string{"Aria Vale@Gilgamesh", "Hollis Crane@Gilgamesh"},
+	},
+	{
+		ReportID:     "FakeWipeReport11",
+		Characters:   8,
+		Progressions: 8,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureTOP: {false, 6210, true}},
+	},
+	{
+		// the kill was done with echo and is ignored
+		ReportID:     "FakeEchoReport11",
+		Characters:   8,
+		Progressions: 8,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP9S: {false, 5033, true}},
+	},
+	{
+		ReportID:     "FakeMultiZone111",
+		Characters:   8,
+		Progressions: 16,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP9S: {true, 0, true}, fixtureP10S: {false, 3888, true}},
+	},
+	{
+		ReportID:     "FakeNonStdComp11",
+		Characters:   8,
+		Progressions: 8,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP9S: {true, 0, false}},
+		Members:      []string{"Pim Larkspur@Gilgamesh"},
+	},
+	{
+		ReportID:     "FakeCrossServer1",
+		Characters:   8,
+		Progressions: 8,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP10S: {true, 0, true}},
+		Members:      []string{"Isolde Fenn@Cactuar", "Lysander Brook@Balmung"},
+	},
+	{
+		ReportID:     "FakeRenameRpt111",
+		Characters:   8,
+		Progressions: 8,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP9S: {true, 0, true}},
+		Members:      []string{"Aria Valen@Cactuar"},
+	},
+	{
+		// aria vale swaps from paladin to gunbreaker, the paladin wipes are kept as their own progression
+		ReportID:     "FakeJobSwapRpt11",
+		Characters:   8,
+		Progressions: 9,
+		Statics:      1,
+		Best:         map[string]fixtureProgression{fixtureP9S: {true, 0, true}},
+	},
+}
+
+// fflogsTestAPIVersions are the apis the fake fflogs server serves the fixtures through.
+var fflogsTestAPIVersions = []string{FFLogsAPIVersion1, FFLogsAPIVersion2}
+
+func TestHandleFFLogReportFixtures(t *testing.T) {
+	for _, apiVersion := range fflogsTestAPIVersions {
+		for _, tt := range fixtureReportTests {
+			t.Run(apiVersion+"/"+tt.ReportID, func(t *testing.T) {
+				config := newTestConfig(t)
+				config.FFLogsAPIVersion = apiVersion
+				db, fflogHandler := newTestHandlers(t, config)
+				report := importTestReport(t, db, fflogHandler, tt.ReportID)
+				if report.Outcome != ReportOutcomeImported {
+					t.Errorf("outcome = %s, want %s", report.Outcome, ReportOutcomeImported)
+				}
+				if report.CharacterCount != tt.Characters {
+					t.Errorf("character count = %d, want %d", report.CharacterCount, tt.Characters)
+				}
+				if report.ProgressionCount != tt.Progressions {
+					t.Errorf("progression count = %d, want %d", report.ProgressionCount, tt.Progressions)
+				}
+				if !db.HasFFLogsReport(tt.ReportID) {
+					t.Errorf("report was not recorded")
+				}
+
+				characters := make([]Character, 0)
+				if err := db.Conn.Find(&characters).Error; err != nil {
+					t.Fatal(err)
+				}
+				if len(characters) != tt.Characters {
+					t.Errorf("%d characters saved, want %d", len(characters), tt.Characters)
+				}
+				for _, character := range characters {
+					if character.UID == "" || character.GameID == 0 {
+						t.Errorf("%s @ %s is missing its uid or game id", character.Name, character.Server)
+					}
+					characterProgressions, err := db.FetchBestCharacterProgressions(character.ID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(characterProgressions) != len(tt.Best) {
+						t.Errorf("%s @ %s has progression on %d encounters, want %d", character.Name, character.Server, len(characterProgressions), len(tt.Best))
+					}
+					for _, characterProgression := range characterProgressions {
+						want, ok := tt.Best[characterProgression.EncounterInfo.ZoneName]
+						if !ok {
+							t.Errorf("%s @ %s has unexpected encounter %s", character.Name, character.Server, characterProgression.EncounterInfo.ZoneName)
+							continue
+						}
+						got := fixtureProgression{characterProgression.IsKill, characterProgression.FightPercentage, characterProgression.IsStandardComposition}
+						if got != want {
+							t.Errorf("%s @ %s best %s = %+v, want %+v", character.Name, character.Server, characterProgression.EncounterInfo.ZoneName, got, want)
+						}
+					}
+				}
+				for _, member := range tt.Members {
+					found := false
+					for _, character := range characters {
+						if character.Name+"@"+character.Server == member {
+							found = true
+							break
+						}
+					}
+					if !found {
+						t.Errorf("character %s was not imported", member)
+					}
+				}
+
+				var statics int64
+				if err := db.Conn.Model(&Static{}).Count(&statics).Error; err != nil {
+					t.Fatal(err)
+				}
+				if statics != tt.Statics {
+					t.Errorf("%d statics saved, want %d", statics, tt.Statics)
+				}
+
+				// importing the same report again finds nothing new
+				report = importTestReport(t, db, fflogHandler, tt.ReportID)
+				if report.Outcome != ReportOutcomeNoImprovements {
+					t.Errorf("reimport outcome = %s, want %s", report.Outcome, ReportOutcomeNoImprovements)
+				}
+			})
+		}
+	}
+}
+
+func TestFFLogsFixtureProgressionOverReports(t *testing.T) {
+	db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	// the rename report is the same party after one member changed name and server
+	report := importTestReport(t, db, fflogHandler, "FakeRenameRpt111")
+	if report.Outcome != ReportOutcomeNoImprovements {
+		t.Errorf("outcome = %s, want %s", report.Outcome, ReportOutcomeNoImprovements)
+	}
+	var count int64
+	if err := db.Conn.Model(&Character{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 8 {
+		t.Errorf("%d characters saved, want 8", count)
+	}
+	character, err := db.FetchCharacterFromGameID(270886838)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if character.Name != "Aria Valen" || character.Server != "Cactuar" {
+		t.Errorf("renamed character is %s @ %s, want Aria Valen @ Cactuar", character.Name, character.Server)
+	}
+}
+
+func TestIsFFLogsErrorTransient(t *testing.T) {
+	for _, tt := range []struct {
+		StatusCode int
+		Transient  bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	} {
+		if got := IsFFLogsErrorTransient(&FFLogsStatusError{StatusCode: tt.StatusCode}); got != tt.Transient {
+			t.Errorf("status %d transient = %t, want %t", tt.StatusCode, got, tt.Transient)
+		}
+	}
+	if !IsFFLogsErrorTransient(&net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}) {
+		t.Errorf("network error should be transient")
+	}
+	if IsFFLogsErrorTransient(ErrUnknownWorld) {
+		t.Errorf("%s should not be transient", ErrUnknownWorld)
+	}
+}
+
+func TestFakeFFLogsErrorStatuses(t *testing.T) {
+	for _, apiVersion := range fflogsTestAPIVersions {
+		config := newTestConfig(t)
+		config.FFLogsAPIVersion = apiVersion
+		_, fflogHandler := newTestHandlers(t, config)
+		for _, tt := range []struct {
+			ReportID  string
+			Transient bool
+		}{
+			// statuses.json
+			{"FakeRateLimited1", true},
+			{"FakeServerError1", true},
+			// missing and private reports
+			{"FakeMissingRpt11", false},
+		} {
+			_, err := fflogHandler.FetchReport(tt.ReportID)
+			if err == nil {
+				t.Errorf("%s %s: expected an error", apiVersion, tt.ReportID)
+				continue
+			}
+			if got := IsFFLogsErrorTransient(err); got != tt.Transient {
+				t.Errorf("%s %s: transient = %t, want %t (%s)", apiVersion, tt.ReportID, got, tt.Transient, err)
+			}
+		}
+	}
+}
+
+func TestFakeFFLogsGuildReports(t *testing.T) {
+	for _, apiVersion := range fflogsTestAPIVersions {
+		config := newTestConfig(t)
+		config.FFLogsAPIVersion = apiVersion
+		_, fflogHandler := newTestHandlers(t, config)
+		codes, err := fflogHandler.FetchGuildReportCodes(DiscoveryGuild{Name: "Fixture Static", Server: "Gilgamesh", Region: "NA"}, time.Time{})
+		if err != nil {
+			t.Fatalf("%s: %s", apiVersion, err)
+		}
+		if len(codes) == 0 || codes[0] != "FakeWipeReport11" {
+			t.Errorf("%s: guild reports = %v, want the guild_reports.json list", apiVersion, codes)
+		}
+		if _, err := fflogHandler.FetchGuildReportCodes(DiscoveryGuild{Name: "Unknown", Server: "Gilgamesh", Region: "NA"}, time.Time{}); err == nil {
+			t.Errorf("%s: unknown guild did not return an error", apiVersion)
+		}
+	}
+}
+
+func TestFixtureProgressionsDerivedFromPulls(t *testing.T) {
+	for _, tt := range fixtureReportTests {
+		t.Run(tt.ReportID, func(t *testing.T) {
+			db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+			importTestReport(t, db, fflogHandler, tt.ReportID)
+			characterProgressions := make([]CharacterProgression, 0)
+			if err := db.Conn.Find(&characterProgressions).Error; err != nil {
+				t.Fatal(err)
+			}
+			for _, characterProgression := range characterProgressions {
+				characterPulls := make([]CharacterPull, 0)
+				if err := db.Conn.Where("character_id = ? AND encounter_info_id = ? AND job = ?", characterProgression.CharacterID, characterProgression.EncounterInfoID, characterProgression.Job).Find(&characterPulls).Error; err != nil {
+					t.Fatal(err)
+				}
+				if len(characterPulls) == 0 {
+					t.Errorf("character %d encounter %d %s has no stored pulls", characterProgression.CharacterID, characterProgression.EncounterInfoID, characterProgression.Job)
+					continue
+				}
+				// the best only view can be rebuilt from the stored pulls
+				derived := characterProgressionFromPulls(characterPulls)
+				if derived.IsKill != characterProgression.IsKill || derived.FightPercentage != characterProgression.FightPercentage || derived.Duration != characterProgression.Duration {
+					t.Errorf("character %d encounter %d %s derived from %d pulls = %+v, want %+v", characterProgression.CharacterID, characterProgression.EncounterInfoID, characterProgression.Job, len(characterPulls), derived, characterProgression)
+				}
+			}
+		})
+	}
+}
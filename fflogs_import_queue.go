@@ -1,66 +1,278 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"net/url"
 	"sync"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+const importRetryBaseDelay = time.Second * 30
+const importRetryMaxDelay = time.Hour
+
 type FFLogsImportQueue struct {
-	lock    sync.Mutex
-	reports []string
-	db      *DatabaseHandler
-	fflog   *FFLogsHandler
+	lock        sync.Mutex
+	jobs        []*ImportJob
+	busy        map[string]bool
+	db          *DatabaseHandler
+	fflog       *FFLogsHandler
+	maxAttempts int
+	workers     int
 }
 
-func NewFFLogsImportQueue(config *Config, db *DatabaseHandler) (*FFLogsImportQueue, error) {
-	fflogHandler, err := NewFFLogsHandler(config)
+func NewFFLogsImportQueue(config *Config, db *DatabaseHandler, fflogHandler *FFLogsHandler) (*FFLogsImportQueue, error) {
+	// reload jobs left over from previous run
+	pendingJobs, err := db.FetchPendingImportJobs()
 	if err != nil {
 		return nil, err
 	}
+	jobs := make([]*ImportJob, 0, len(pendingJobs))
+	for i := range pendingJobs {
+		jobs = append(jobs, &pendingJobs[i])
+	}
+	if len(jobs) > 0 {
+		log.Printf("Restored %d FFLogs report(s) to queue.\n", len(jobs))
+	}
 	return &FFLogsImportQueue{
-		reports: make([]string, 0),
-		db:      db,
-		fflog:   fflogHandler,
+		jobs:        jobs,
+		busy:        make(map[string]bool),
+		db:          db,
+		fflog:       fflogHandler,
+		maxAttempts: config.ImportMaxAttempts,
+		workers:     config.ImportWorkers,
 	}, nil
 }
 
-func (f *FFLogsImportQueue) Add(reportID string) error {
+func (f *FFLogsImportQueue) Add(reportID string, submitterIP string) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
-	for _, existingReportID := range f.reports {
-		if existingReportID == reportID {
+	for _, existingJob := range f.jobs {
+		if existingJob.ReportID == reportID {
 			return ErrAlreadyInQueue
 		}
 	}
-	f.reports = append(f.reports, reportID)
+	// reuse job record from previous import attempt
+	importJob, err := f.db.FetchImportJobFromReportID(reportID)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	importJob.ReportID = reportID
+	importJob.Status = ImportStatusQueued
+	importJob.Attempts = 0
+	importJob.SubmitterIP = submitterIP
+	importJob.EnqueuedAt = time.Now()
+	importJob.NextAttemptAt = importJob.EnqueuedAt
+	importJob.LastError = ""
+	importJob.PermanentFailure = false
+	if err := f.db.SaveImportJob(&importJob); err != nil {
+		return err
+	}
+	f.jobs = append(f.jobs, &importJob)
 	log.Printf("Added FFLogs report %s to queue.\n", reportID)
 	return nil
 }
 
-func (f *FFLogsImportQueue) Start() {
+// Jobs returns a copy of every job in the queue.
+func (f *FFLogsImportQueue) Jobs() []ImportJob {
+	f.lock.Lock()
 	defer f.lock.Unlock()
-	for range time.Tick(time.Second * 1) {
-		f.lock.Lock()
-		if len(f.reports) == 0 {
-			f.lock.Unlock()
-			continue
+	out := make([]ImportJob, 0, len(f.jobs))
+	for _, importJob := range f.jobs {
+		out = append(out, *importJob)
+	}
+	return out
+}
+
+// Remove takes a job out of the queue, jobs that are being processed can not be removed.
+func (f *FFLogsImportQueue) Remove(reportID string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.busy[reportID] {
+		return ErrImportJobBusy
+	}
+	for i, importJob := range f.jobs {
+		if importJob.ReportID == reportID {
+			f.jobs = append(f.jobs[:i], f.jobs[i+1:]...)
+			importJob.Status = ImportStatusRemoved
+			log.Printf("Removed FFLogs report %s from queue.\n", reportID)
+			return f.db.SaveImportJob(importJob)
 		}
-		reportID := ""
-		reportID, f.reports = f.reports[0], f.reports[1:]
-		log.Printf("Processing FFLogs report %s.\n", reportID)
-		characterReports, err := f.fflog.FetchCharacterReports(reportID)
+	}
+	return ErrNotInQueue
+}
+
+// Prioritize moves a job to the front of the queue and clears any retry delay, jobs that are being processed can not be moved.
+func (f *FFLogsImportQueue) Prioritize(reportID string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.busy[reportID] {
+		return ErrImportJobBusy
+	}
+	for i, importJob := range f.jobs {
+		if importJob.ReportID == reportID {
+			importJob.NextAttemptAt = time.Now()
+			f.jobs = append([]*ImportJob{importJob}, append(f.jobs[:i], f.jobs[i+1:]...)...)
+			return nil
+		}
+	}
+	return ErrNotInQueue
+}
+
+// update changes a job while holding the queue lock, as the admin console reads jobs that are being processed, then saves it.
+func (f *FFLogsImportQueue) update(importJob *ImportJob, change func(importJob *ImportJob)) {
+	f.lock.Lock()
+	change(importJob)
+	savedJob := *importJob
+	f.lock.Unlock()
+	if err := f.db.SaveImportJob(&savedJob); err != nil {
+		log.Printf("Error saving import job for FFLogs report %s: %s\n", importJob.ReportID, err.Error())
+	}
+}
+
+func (f *FFLogsImportQueue) setStatus(importJob *ImportJob, status string) {
+	f.update(importJob, func(importJob *ImportJob) {
+		importJob.Status = status
+	})
+}
+
+// importErrorMessage returns an error message safe to display, request urls contain the api key.
+func importErrorMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// retryDelay returns the exponential backoff delay before the given attempt number is retried.
+func retryDelay(attempts int) time.Duration {
+	delay := importRetryBaseDelay
+	for i := 1; i < attempts && delay < importRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > importRetryMaxDelay {
+		return importRetryMaxDelay
+	}
+	return delay
+}
+
+// isImportErrorTransient returns true if a failed import may succeed if tried again later.
+func isImportErrorTransient(err error) bool {
+	return IsFFLogsErrorTransient(err) || IsDatabaseBusyError(err)
+}
+
+// fail records a failed import attempt, transient failures are put back in the queue until max attempts is reached.
+func (f *FFLogsImportQueue) fail(importJob *ImportJob, err error) {
+	log.Printf("Error importing FFLogs report %s: %s\n", importJob.ReportID, err.Error())
+	retry := false
+	nextAttemptAt := time.Time{}
+	f.update(importJob, func(importJob *ImportJob) {
+		importJob.LastError = importErrorMessage(err)
+		importJob.PermanentFailure = !isImportErrorTransient(err)
+		if importJob.PermanentFailure || importJob.Attempts >= f.maxAttempts {
+			importJob.Status = ImportStatusFailed
+			return
+		}
+		retry = true
+		importJob.NextAttemptAt = time.Now().Add(retryDelay(importJob.Attempts))
+		importJob.Status = ImportStatusRetrying
+		nextAttemptAt = importJob.NextAttemptAt
+	})
+	if retry {
+		log.Printf("Retrying FFLogs report %s at %s.\n", importJob.ReportID, nextAttemptAt.Format(time.RFC3339))
+	}
+	f.release(importJob, retry)
+}
+
+func (f *FFLogsImportQueue) finish(importJob *ImportJob, report Report, err error) {
+	if err != nil {
+		log.Printf("Error importing FFLogs report %s: %s\n", importJob.ReportID, err.Error())
+	} else {
+		log.Printf("Finished processing FFLogs report %s.\n", importJob.ReportID)
+	}
+	f.update(importJob, func(importJob *ImportJob) {
+		importJob.LastError = ""
+		importJob.Status = ImportStatusDone
 		if err != nil {
-			log.Printf("Error importing FFLogs report %s: %s\n", reportID, err.Error())
-			f.lock.Unlock()
+			importJob.LastError = importErrorMessage(err)
+			importJob.Status = ImportStatusFailed
+			return
+		}
+		importJob.CharacterCount = report.CharacterCount
+		importJob.ProgressionCount = report.ProgressionCount
+	})
+	f.release(importJob, false)
+}
+
+// claim returns the first job that is ready to be processed and marks it as busy.
+func (f *FFLogsImportQueue) claim() *ImportJob {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	now := time.Now()
+	for _, importJob := range f.jobs {
+		if f.busy[importJob.ReportID] || importJob.NextAttemptAt.After(now) {
 			continue
 		}
-		for _, characterReport := range characterReports {
-			if err := f.db.HandleFFLogCharacterReport(characterReport); err != nil {
-				log.Printf("Error importing FFLogs report %s: %s\n", reportID, err.Error())
-			}
+		f.busy[importJob.ReportID] = true
+		return importJob
+	}
+	return nil
+}
+
+// release frees a claimed job, removing it from the queue unless it is to be retried.
+func (f *FFLogsImportQueue) release(importJob *ImportJob, retry bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.busy, importJob.ReportID)
+	if retry {
+		return
+	}
+	for i, existingJob := range f.jobs {
+		if existingJob == importJob {
+			f.jobs = append(f.jobs[:i], f.jobs[i+1:]...)
+			break
+		}
+	}
+}
+
+func (f *FFLogsImportQueue) process(importJob *ImportJob) {
+	log.Printf("Processing FFLogs report %s.\n", importJob.ReportID)
+	f.update(importJob, func(importJob *ImportJob) {
+		importJob.Attempts++
+		importJob.CharacterCount = 0
+		importJob.ProgressionCount = 0
+		importJob.Status = ImportStatusFetching
+	})
+	fflReport, err := f.fflog.FetchReport(importJob.ReportID)
+	if err != nil {
+		f.fail(importJob, err)
+		return
+	}
+	f.setStatus(importJob, ImportStatusWriting)
+	report, err := f.db.HandleFFLogReport(fflReport)
+	if IsDatabaseBusyError(err) {
+		// another writer held the database for too long, nothing was written so the report is retried
+		f.fail(importJob, err)
+		return
+	}
+	f.finish(importJob, report, err)
+}
+
+func (f *FFLogsImportQueue) work() {
+	for range time.Tick(time.Second * 1) {
+		for importJob := f.claim(); importJob != nil; importJob = f.claim() {
+			f.process(importJob)
 		}
-		log.Printf("Finished processing FFLogs report %s.\n", reportID)
-		f.lock.Unlock()
+	}
+}
+
+// Start launches the import workers.
+func (f *FFLogsImportQueue) Start() {
+	log.Printf("Starting %d FFLogs import worker(s).\n", f.workers)
+	for i := 0; i < f.workers; i++ {
+		go f.work()
 	}
 }
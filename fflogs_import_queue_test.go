@@ -0,0 +1,90 @@
+package main
+
+import "testing"
+
+func TestImportQueueProcess(t *testing.T) {
+	for _, tt := range []struct {
+		ReportID         string
+		Status           string
+		PermanentFailure bool
+		Queued           bool
+	}{
+		{"FakeKillReport11", ImportStatusDone, false, false},
+		{"FakeRateLimited1", ImportStatusRetrying, false, true},
+		{"FakeServerError1", ImportStatusRetrying, false, true},
+		{"FakeMissingRpt11", ImportStatusFailed, true, false},
+	} {
+		t.Run(tt.ReportID, func(t *testing.T) {
+			config := newTestConfig(t)
+			config.ImportMaxAttempts = 3
+			db, fflogHandler := newTestHandlers(t, config)
+			queue, err := NewFFLogsImportQueue(config, db, fflogHandler)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := queue.Add(tt.ReportID, "test"); err != nil {
+				t.Fatal(err)
+			}
+			importJob := queue.claim()
+			if importJob == nil {
+				t.Fatal("no job was ready to process")
+			}
+			queue.process(importJob)
+			savedJob, err := db.FetchImportJobFromReportID(tt.ReportID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if savedJob.Status != tt.Status || savedJob.PermanentFailure != tt.PermanentFailure {
+				t.Errorf("status = %s permanent = %t, want %s permanent = %t", savedJob.Status, savedJob.PermanentFailure, tt.Status, tt.PermanentFailure)
+			}
+			if queued := len(queue.Jobs()) > 0; queued != tt.Queued {
+				t.Errorf("queued = %t, want %t", queued, tt.Queued)
+			}
+		})
+	}
+}
+
+func TestImportQueueBusyJob(t *testing.T) {
+	config := newTestConfig(t)
+	db, fflogHandler := newTestHandlers(t, config)
+	queue, err := NewFFLogsImportQueue(config, db, fflogHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, reportID := range []string{"FakeKillReport11", "FakeWipeReport11"} {
+		if err := queue.Add(reportID, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	importJob := queue.claim()
+	if importJob == nil || importJob.ReportID != "FakeKillReport11" {
+		t.Fatalf("claimed %v, want FakeKillReport11", importJob)
+	}
+	if err := queue.Prioritize(importJob.ReportID); err != ErrImportJobBusy {
+		t.Errorf("prioritize busy job: err = %v, want %v", err, ErrImportJobBusy)
+	}
+	if err := queue.Remove(importJob.ReportID); err != ErrImportJobBusy {
+		t.Errorf("remove busy job: err = %v, want %v", err, ErrImportJobBusy)
+	}
+	if err := queue.Prioritize("FakeWipeReport11"); err != nil {
+		t.Fatal(err)
+	}
+	// the admin console reads the queue while a worker is processing
+	done := make(chan struct{})
+	go func() {
+		queue.process(importJob)
+		close(done)
+	}()
+	for processing := true; processing; {
+		select {
+		case <-done:
+			processing = false
+		default:
+			queue.Jobs()
+		}
+	}
+	jobs := queue.Jobs()
+	if len(jobs) != 1 || jobs[0].ReportID != "FakeWipeReport11" {
+		t.Errorf("queue = %+v, want only FakeWipeReport11", jobs)
+	}
+}
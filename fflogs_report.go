@@ -4,22 +4,70 @@ import (
 	"context"
 	"time"
 
-	"github.com/RyuaNerin/go-fflogs"
 	"github.com/RyuaNerin/go-fflogs/structure"
 )
 
+// FFLogReport contains information about a report and the progression of every character in it.
+type FFLogReport struct {
+	ReportID    string
+	Title       string
+	Owner       string
+	ZoneID      int64
+	StartTime   time.Time
+	EndTime     time.Time
+	GameVersion int64
+	Characters  []FFLogCharacterReport
+	Parties     []FFLogPartyReport
+}
+
+// FFLogPartyReport contains the best encounters of a full party that played together in a report.
+type FFLogPartyReport struct {
+	ReportID     string
+	CompareHash  string
+	MemberHashes []string
+	Progression  []CharacterProgression
+	Pulls        map[string]int
+}
+
+// HasMember returns true if any member of the party is in the given set of character compare hashes.
+func (pr FFLogPartyReport) HasMember(compareHashes map[string]bool) bool {
+	for _, memberHash := range pr.MemberHashes {
+		if compareHashes[memberHash] {
+			return true
+		}
+	}
+	return false
+}
+
 // FFLogCharacterReport contains information about a character's best encounters in a report.
 type FFLogCharacterReport struct {
 	ReportID    string
 	Character   Character
 	Progression []CharacterProgression
+	Pulls       []CharacterPull
+}
+
+// LastSeen returns the end time of the character's last fight in the report.
+func (cr FFLogCharacterReport) LastSeen() time.Time {
+	lastSeen := time.Time{}
+	for _, characterProgression := range cr.Progression {
+		if characterProgression.Time.After(lastSeen) {
+			lastSeen = characterProgression.Time
+		}
+	}
+	for _, characterPull := range cr.Pulls {
+		if characterPull.EndTime.After(lastSeen) {
+			lastSeen = characterPull.EndTime
+		}
+	}
+	return lastSeen
 }
 
 func (ffl FFLogsHandler) rawFetchReportFights(reportID string) (*structure.Fights, error) {
-	reportOpts := fflogs.ReportFightsOptions{
-		Code: reportID,
+	if err := ffl.limiter.Wait(context.Background()); err != nil {
+		return nil, err
 	}
-	return ffl.client.ReportFights(context.Background(), &reportOpts)
+	return ffl.source.FetchReportFights(context.Background(), reportID)
 }
 
 func isFFLogsFriendlyInEncounter(fflFightsFriendly *structure.FightsFriendly, fflFight *structure.FightsFight) bool {
@@ -77,6 +125,7 @@ func getCharacterProgressForFFLogsFightsFriendly(reportID string, fflFights *str
 		CompareHash: FFLogsCharacterHash(fflFightsFriendly),
 		Name:        fflFightsFriendly.Name,
 		Server:      fflFightsFriendly.Server,
+		GameID:      fflFightsFriendly.GUID,
 	}
 	// generate character progressions
 	characterProgressions := make([]CharacterProgression, 0)
@@ -147,7 +196,7 @@ func getCharacterProgressForFFLogsFightsFriendly(reportID string, fflFights *str
 				IsKill:                hasKill,
 				IsStandardComposition: bestStandardComp,
 				HasEcho:               false,
-				Job:                   fflFightsFriendly.Type,
+				Job:                   NormalizeJob(fflFightsFriendly.Type),
 				EncounterInfo: EncounterInfo{
 					CompareHash: encounterHash,
 					ZoneID:      bestZoneID,
@@ -163,14 +212,92 @@ func getCharacterProgressForFFLogsFightsFriendly(reportID string, fflFights *str
 
 }
 
-func (ffl FFLogsHandler) FetchCharacterReports(reportID string) ([]FFLogCharacterReport, error) {
+func getCharacterPullsForFFLogsFightsFriendly(reportID string, fflFights *structure.Fights, fflFightsFriendly *structure.FightsFriendly) []CharacterPull {
+	out := make([]CharacterPull, 0)
+	for _, fflFight := range fflFights.Fights {
+		if !IsFFLogsEncounterValid(&fflFight) || !isFFLogsFriendlyInEncounter(fflFightsFriendly, &fflFight) {
+			continue
+		}
+		out = append(out, CharacterPull{
+			ReportID:        reportID,
+			FightID:         fflFight.ID,
+			StartTime:       time.UnixMilli(fflFights.Start + fflFight.StartTime),
+			EndTime:         time.UnixMilli(fflFights.Start + fflFight.EndTime),
+			Duration:        fflFight.EndTime - fflFight.StartTime,
+			FightPercentage: *fflFight.FightPercentage,
+			Phase:           derefInt64(fflFight.LastPhaseForPercentageDisplay),
+			PhasePercentage: *fflFight.BossPercentage,
+			IsKill:          *fflFight.Kill,
+			Job:             NormalizeJob(fflFightsFriendly.Type),
+			EncounterInfo: EncounterInfo{
+				CompareHash: FFLogsEncounterInfoHash(&fflFight),
+			},
+		})
+	}
+	return out
+}
+
+// getFFLogsPartyReports groups the valid fights of a report by the full party that played them.
+func getFFLogsPartyReports(reportID string, fflFights *structure.Fights) []FFLogPartyReport {
+	partyMembers := make(map[string][]string)
+	partyFights := make(map[string]*structure.FightsFriendly)
+	partyPulls := make(map[string]map[string]int)
+	partyOrder := make([]string, 0)
+	for _, fflFight := range fflFights.Fights {
+		if !IsFFLogsEncounterValid(&fflFight) {
+			continue
+		}
+		memberHashes := make([]string, 0, staticPartySize)
+		for _, fflFightsFriendly := range fflFights.Friendlies {
+			if fflFightsFriendly.Server != "" && isFFLogsFriendlyInEncounter(&fflFightsFriendly, &fflFight) {
+				memberHashes = append(memberHashes, FFLogsCharacterHash(&fflFightsFriendly))
+			}
+		}
+		if len(memberHashes) != staticPartySize {
+			continue
+		}
+		compareHash := StaticCompareHash(memberHashes)
+		if partyFights[compareHash] == nil {
+			partyMembers[compareHash] = memberHashes
+			partyFights[compareHash] = &structure.FightsFriendly{}
+			partyPulls[compareHash] = make(map[string]int)
+			partyOrder = append(partyOrder, compareHash)
+		}
+		partyFights[compareHash].Fights = append(partyFights[compareHash].Fights, structure.FightsFriendlyFight{ID: fflFight.ID})
+		partyPulls[compareHash][FFLogsEncounterInfoHash(&fflFight)]++
+	}
+	out := make([]FFLogPartyReport, 0, len(partyOrder))
+	for _, compareHash := range partyOrder {
+		// the party is treated as a single friendly that took part in all of its fights
+		_, progression := getCharacterProgressForFFLogsFightsFriendly(reportID, fflFights, partyFights[compareHash])
+		out = append(out, FFLogPartyReport{
+			ReportID:     reportID,
+			CompareHash:  compareHash,
+			MemberHashes: partyMembers[compareHash],
+			Progression:  progression,
+			Pulls:        partyPulls[compareHash],
+		})
+	}
+	return out
+}
+
+func (ffl FFLogsHandler) FetchReport(reportID string) (FFLogReport, error) {
 	// fetch report
 	fflFights, err := ffl.rawFetchReportFights(reportID)
 	if err != nil {
-		return nil, err
+		return FFLogReport{}, err
+	}
+	out := FFLogReport{
+		ReportID:    reportID,
+		Title:       fflFights.Title,
+		Owner:       fflFights.Owner,
+		ZoneID:      fflFights.Zone,
+		StartTime:   time.UnixMilli(fflFights.Start),
+		EndTime:     time.UnixMilli(fflFights.End),
+		GameVersion: fflFights.GameVersion,
+		Characters:  make([]FFLogCharacterReport, 0),
 	}
 	// generate character reports
-	out := make([]FFLogCharacterReport, 0)
 	for _, fflFightFriendly := range fflFights.Friendlies {
 		if fflFightFriendly.Server == "" {
 			continue
@@ -179,11 +306,13 @@ func (ffl FFLogsHandler) FetchCharacterReports(reportID string) ([]FFLogCharacte
 		if len(characterProgression) == 0 {
 			continue
 		}
-		out = append(out, FFLogCharacterReport{
+		out.Characters = append(out.Characters, FFLogCharacterReport{
 			ReportID:    reportID,
 			Character:   character,
 			Progression: characterProgression,
+			Pulls:       getCharacterPullsForFFLogsFightsFriendly(reportID, fflFights, &fflFightFriendly),
 		})
 	}
+	out.Parties = getFFLogsPartyReports(reportID, fflFights)
 	return out, nil
 }
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RyuaNerin/go-fflogs/structure"
+)
+
+const fflogsV2TokenPath = "/oauth/token"
+const fflogsV2ClientPath = "/api/v2/client"
+
+const fflogsV2ReportFightsQuery = `query ($code: String!) {
+	reportData {
+		report(code: $code) {
+			title
+			owner { name }
+			startTime
+			endTime
+			zone { id }
+			masterData {
+				gameVersion
+				actors(type: "Player") { id gameID name server subType }
+			}
+			fights {
+				id
+				encounterID
+				name
+				startTime
+				endTime
+				kill
+				size
+				difficulty
+				hasEcho
+				standardComposition
+				bossPercentage
+				fightPercentage
+				lastPhase
+				gameZone { id name }
+				friendlyPlayers
+			}
+		}
+	}
+}`
+
+const fflogsV2GuildReportsQuery = `query ($guildName: String!, $serverSlug: String!, $serverRegion: String!, $startTime: Float!) {
+	reportData {
+		reports(guildName: $guildName, guildServerSlug: $serverSlug, guildServerRegion: $serverRegion, startTime: $startTime, limit: 100) {
+			data { code }
+		}
+	}
+}`
+
+const fflogsV2CharacterReportsQuery = `query ($name: String!, $serverSlug: String!, $serverRegion: String!) {
+	characterData {
+		character(name: $name, serverSlug: $serverSlug, serverRegion: $serverRegion) {
+			recentReports(limit: 10) {
+				data { code }
+			}
+		}
+	}
+}`
+
+// FFLogsGraphQLError is an error returned by the v2 graphql api.
+type FFLogsGraphQLError struct {
+	Message string
+}
+
+func (e *FFLogsGraphQLError) Error() string {
+	return fmt.Sprintf("fflogs responded with error: %s", e.Message)
+}
+
+type fflogsV2Response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type fflogsV2ReportFightsData struct {
+	ReportData struct {
+		Report *struct {
+			Title string `json:"title"`
+			Owner struct {
+				Name string `json:"name"`
+			} `json:"owner"`
+			StartTime int64 `json:"startTime"`
+			EndTime   int64 `json:"endTime"`
+			Zone      *struct {
+				ID int64 `json:"id"`
+			} `json:"zone"`
+			MasterData struct {
+				GameVersion int64 `json:"gameVersion"`
+				Actors      []struct {
+					ID      int64  `json:"id"`
+					GameID  int64  `json:"gameID"`
+					Name    string `json:"name"`
+					Server  string `json:"server"`
+					SubType string `json:"subType"`
+				} `json:"actors"`
+			} `json:"masterData"`
+			Fights []struct {
+				ID                  int64    `json:"id"`
+				EncounterID         int64    `json:"encounterID"`
+				Name                string   `json:"name"`
+				StartTime           int64    `json:"startTime"`
+				EndTime             int64    `json:"endTime"`
+				Kill                *bool    `json:"kill"`
+				Size                *int64   `json:"size"`
+				Difficulty          *int64   `json:"difficulty"`
+				HasEcho             *bool    `json:"hasEcho"`
+				StandardComposition *bool    `json:"standardComposition"`
+				BossPercentage      *float64 `json:"bossPercentage"`
+				FightPercentage     *float64 `json:"fightPercentage"`
+				LastPhase           *int64   `json:"lastPhase"`
+				GameZone            *struct {
+					ID   int64  `json:"id"`
+					Name string `json:"name"`
+				} `json:"gameZone"`
+				FriendlyPlayers []int64 `json:"friendlyPlayers"`
+			} `json:"fights"`
+		} `json:"report"`
+	} `json:"reportData"`
+}
+
+type fflogsV2ReportList struct {
+	Data []struct {
+		Code string `json:"code"`
+	} `json:"data"`
+}
+
+func (l *fflogsV2ReportList) codes() []string {
+	if l == nil {
+		return []string{}
+	}
+	out := make([]string, 0, len(l.Data))
+	for _, report := range l.Data {
+		out = append(out, report.Code)
+	}
+	return out
+}
+
+// fflogsV2ServerSlug converts a server name to the slug used by the v2 api.
+func fflogsV2ServerSlug(server string) string {
+	return strings.ToLower(strings.ReplaceAll(server, " ", "-"))
+}
+
+// fflogsV2ReportSource fetches reports from the v2 graphql api using the oauth client credentials flow.
+type fflogsV2ReportSource struct {
+	httpClient   *http.Client
+	baseURL      string
+	clientID     string
+	clientSecret string
+	lock         sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+func newFFLogsV2ReportSource(config *Config, httpClient *http.Client) (*fflogsV2ReportSource, error) {
+	if config.FFLogsClientID == "" || config.FFLogsClientSecret == "" {
+		return nil, errors.New("fflogs client id and secret are required for the v2 api")
+	}
+	return &fflogsV2ReportSource{
+		httpClient:   httpClient,
+		baseURL:      config.FFLogsBaseURL,
+		clientID:     config.FFLogsClientID,
+		clientSecret: config.FFLogsClientSecret,
+	}, nil
+}
+
+// accessToken returns a cached access token, requesting a new one when it has expired.
+func (s *fflogsV2ReportSource) accessToken(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token != "" && time.Now().Before(s.tokenExpires) {
+		return s.token, nil
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+fflogsV2TokenPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(s.clientID, s.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	tokenResp := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.AccessToken == "" {
+		return "", errors.New("fflogs did not return an access token")
+	}
+	s.token = tokenResp.AccessToken
+	// renew a minute early so requests in flight don't use an expired token
+	s.tokenExpires = time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+func (s *fflogsV2ReportSource) query(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+fflogsV2ClientPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	graphResp := fflogsV2Response{}
+	if err := json.NewDecoder(resp.Body).Decode(&graphResp); err != nil {
+		return err
+	}
+	if len(graphResp.Errors) > 0 {
+		return &FFLogsGraphQLError{Message: graphResp.Errors[0].Message}
+	}
+	return json.Unmarshal(graphResp.Data, out)
+}
+
+// fflogsV2Percentage converts a v2 percentage (0-100) to the v1 format (0-10000).
+func fflogsV2Percentage(p *float64) *int64 {
+	if p == nil {
+		return nil
+	}
+	out := int64(math.Round(*p * 100))
+	return &out
+}
+
+func (s *fflogsV2ReportSource) FetchReportFights(ctx context.Context, reportID string) (*structure.Fights, error) {
+	data := fflogsV2ReportFightsData{}
+	if err := s.query(ctx, fflogsV2ReportFightsQuery, map[string]interface{}{"code": reportID}, &data); err != nil {
+		return nil, err
+	}
+	report := data.ReportData.Report
+	if report == nil {
+		return nil, &FFLogsGraphQLError{Message: "report not found"}
+	}
+	// convert to v1 format
+	out := &structure.Fights{
+		Fights:      make([]structure.FightsFight, 0, len(report.Fights)),
+		Friendlies:  make([]structure.FightsFriendly, 0, len(report.MasterData.Actors)),
+		GameVersion: report.MasterData.GameVersion,
+		Title:       report.Title,
+		Owner:       report.Owner.Name,
+		Start:       report.StartTime,
+		End:         report.EndTime,
+	}
+	if report.Zone != nil {
+		out.Zone = report.Zone.ID
+	}
+	for _, fight := range report.Fights {
+		fflFight := structure.FightsFight{
+			ID:                            fight.ID,
+			Boss:                          fight.EncounterID,
+			StartTime:                     fight.StartTime,
+			EndTime:                       fight.EndTime,
+			Name:                          fight.Name,
+			Size:                          fight.Size,
+			Difficulty:                    fight.Difficulty,
+			Kill:                          fight.Kill,
+			StandardComposition:           fight.StandardComposition,
+			HasEcho:                       fight.HasEcho,
+			BossPercentage:                fflogsV2Percentage(fight.BossPercentage),
+			FightPercentage:               fflogsV2Percentage(fight.FightPercentage),
+			LastPhaseForPercentageDisplay: fight.LastPhase,
+		}
+		if fight.GameZone != nil {
+			fflFight.ZoneID = fight.GameZone.ID
+			fflFight.ZoneName = fight.GameZone.Name
+		}
+		out.Fights = append(out.Fights, fflFight)
+	}
+	for _, actor := range report.MasterData.Actors {
+		friendly := structure.FightsFriendly{
+			Name:   actor.Name,
+			ID:     actor.ID,
+			GUID:   actor.GameID,
+			Type:   actor.SubType,
+			Server: actor.Server,
+			Fights: make([]structure.FightsFriendlyFight, 0),
+		}
+		for _, fight := range report.Fights {
+			for _, friendlyID := range fight.FriendlyPlayers {
+				if friendlyID == actor.ID {
+					friendly.Fights = append(friendly.Fights, structure.FightsFriendlyFight{ID: fight.ID})
+					break
+				}
+			}
+		}
+		out.Friendlies = append(out.Friendlies, friendly)
+	}
+	return out, nil
+}
+
+func (s *fflogsV2ReportSource) FetchGuildReportCodes(ctx context.Context, guild DiscoveryGuild, since time.Time) ([]string, error) {
+	data := struct {
+		ReportData struct {
+			Reports *fflogsV2ReportList `json:"reports"`
+		} `json:"reportData"`
+	}{}
+	variables := map[string]interface{}{
+		"guildName":    guild.Name,
+		"serverSlug":   fflogsV2ServerSlug(guild.Server),
+		"serverRegion": guild.Region,
+		"startTime":    since.UnixMilli(),
+	}
+	if err := s.query(ctx, fflogsV2GuildReportsQuery, variables, &data); err != nil {
+		return nil, err
+	}
+	return data.ReportData.Reports.codes(), nil
+}
+
+func (s *fflogsV2ReportSource) FetchCharacterReportCodes(ctx context.Context, character Character) ([]string, error) {
+	data := struct {
+		CharacterData struct {
+			Character *struct {
+				RecentReports *fflogsV2ReportList `json:"recentReports"`
+			} `json:"character"`
+		} `json:"characterData"`
+	}{}
+	region := GetServerRegion(character.Server)
+	if region == "" {
+		return nil, ErrUnknownWorld
+	}
+	variables := map[string]interface{}{
+		"name":         character.Name,
+		"serverSlug":   fflogsV2ServerSlug(character.Server),
+		"serverRegion": region,
+	}
+	if err := s.query(ctx, fflogsV2CharacterReportsQuery, variables, &data); err != nil {
+		return nil, err
+	}
+	if data.CharacterData.Character == nil {
+		return []string{}, nil
+	}
+	return data.CharacterData.Character.RecentReports.codes(), nil
+}
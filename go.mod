@@ -5,6 +5,7 @@ go 1.18
 require (
 	github.com/RyuaNerin/go-fflogs v0.0.0-20220126135801-559f19edc42e
 	github.com/martinlindhe/base36 v1.1.1
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/tdewolff/minify/v2 v2.12.6
 	golang.org/x/time v0.3.0
 	gorm.io/driver/sqlite v1.5.0
@@ -16,7 +17,6 @@ require (
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
-	github.com/mattn/go-sqlite3 v1.14.16 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/spf13/cast v1.5.0 // indirect
@@ -2,10 +2,13 @@ package main
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"fmt"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/RyuaNerin/go-fflogs/structure"
@@ -16,6 +19,11 @@ var fflogReportUrlRegex = regexp.MustCompile(`https?\:\/\/www\.fflogs\.com\/repo
 
 var JobsMap = map[string]string{}
 
+var jobRoles = map[string]string{
+	"pld": "tank", "war": "tank", "drk": "tank", "gnb": "tank",
+	"whm": "healer", "sch": "healer", "ast": "healer", "sge": "healer",
+}
+
 const uuidLength = 6
 
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyz1234567890")
@@ -38,6 +46,34 @@ func FFLogsCharacterHash(fightsFriendly *structure.FightsFriendly) string {
 	return base36.EncodeBytes(hashBytes[:])
 }
 
+// StaticCompareHash generates a hash identifying a group of characters regardless of order.
+func StaticCompareHash(characterHashes []string) string {
+	sorted := append([]string{}, characterHashes...)
+	sort.Strings(sorted)
+	hashBytes := sha256.Sum256([]byte(strings.Join(sorted, ",")))
+	return base36.EncodeBytes(hashBytes[:])
+}
+
+// NormalizeJob converts an FFLogs friendly type (e.g. "WhiteMage") to its job abbreviation (e.g. "whm").
+// Unknown jobs are lowercased with spaces removed.
+func NormalizeJob(job string) string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	key := strings.ToLower(strings.ReplaceAll(job, " ", ""))
+	if abbreviation, ok := JobsMap[key]; ok {
+		return abbreviation
+	}
+	return key
+}
+
+// JobRole returns the role of a normalized job, used to color job icons.
+func JobRole(job string) string {
+	if role, ok := jobRoles[job]; ok {
+		return role
+	}
+	return "dps"
+}
+
 func FFLogReportURLToReportID(reportURL string) string {
 	results := fflogReportUrlRegex.FindAllStringSubmatch(reportURL, -1)
 	if len(results) == 0 || len(results[0]) < 2 {
@@ -49,6 +85,13 @@ func FFLogReportURLToReportID(reportURL string) string {
 	return results[0][1]
 }
 
+func derefInt64(v *int64) int64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
 func IsFFLogsEncounterValid(fflFight *structure.FightsFight) bool {
 	return fflFight.HasEcho != nil && !*fflFight.HasEcho && fflFight.Difficulty != nil && *fflFight.Difficulty != 0 && fflFight.BossPercentage != nil && fflFight.FightPercentage != nil && fflFight.Kill != nil
 }
@@ -64,6 +107,22 @@ func ReadUserIP(r *http.Request) string {
 	return IPAddress
 }
 
+// IsAdminRequest returns true if the request carries the configured admin token or basic auth login.
+func IsAdminRequest(r *http.Request, config *Config) bool {
+	if username, password, ok := r.BasicAuth(); ok {
+		if config.AdminUsername == "" || config.AdminPassword == "" {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(username), []byte(config.AdminUsername)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(config.AdminPassword)) == 1
+	}
+	if config.AdminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) == 1
+}
+
 func EncounterDisplayListFromEncounterInfoList(list []EncounterInfo, config *Config) []displayEncounterData {
 	out := make([]displayEncounterData, 0)
 	for _, displayEncounterInfo := range config.DisplayedEncounters {
@@ -86,21 +145,124 @@ func EncounterDisplayListFromEncounterInfoList(list []EncounterInfo, config *Con
 	return out
 }
 
+// GetServerRegion returns the region of a server, empty if the server isn't in the data center map.
 func GetServerRegion(serverName string) string {
+	dataCenter := GetServerDataCenter(serverName)
+	if dataCenter == "" {
+		return ""
+	}
+	return GetDataCenterRegion(dataCenter)
+}
+
+// GetServerDataCenter returns the data center of a server, empty if the server is unknown.
+func GetServerDataCenter(serverName string) string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
 	for datacenter, serverList := range dcServerMap {
 		for _, serverListName := range serverList {
 			if strings.EqualFold(serverName, serverListName) {
-				return dcRegionMap[datacenter]
+				return datacenter
 			}
 		}
 	}
-	return "na"
+	return ""
+}
+
+// GetDataCenterRegion returns the region of a data center, empty if the data center is unknown.
+func GetDataCenterRegion(dataCenter string) string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	for datacenter, region := range dcRegionMap {
+		if strings.EqualFold(dataCenter, datacenter) {
+			return region
+		}
+	}
+	return ""
+}
+
+// GetDataCenters returns the names of all known data centers sorted by name.
+func GetDataCenters() []string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	out := make([]string, 0, len(dcServerMap))
+	for datacenter := range dcServerMap {
+		out = append(out, datacenter)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GetRegions returns all known regions sorted by name.
+func GetRegions() []string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	out := make([]string, 0)
+	for _, region := range dcRegionMap {
+		if !sliceContains(out, region) {
+			out = append(out, region)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GetRegionDataCenters returns the data centers in a region sorted by name.
+func GetRegionDataCenters(region string) []string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	out := make([]string, 0)
+	for datacenter, dcRegion := range dcRegionMap {
+		if strings.EqualFold(region, dcRegion) {
+			out = append(out, datacenter)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GetDataCenterServers returns the servers in a data center, nil if the data center is unknown.
+func GetDataCenterServers(dataCenter string) []string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	for datacenter, serverList := range dcServerMap {
+		if strings.EqualFold(dataCenter, datacenter) {
+			return serverList
+		}
+	}
+	return nil
+}
+
+// GetRegionServers returns the servers in every data center of a region, nil if the region is unknown.
+func GetRegionServers(region string) []string {
+	dataMapLock.RLock()
+	defer dataMapLock.RUnlock()
+	var out []string
+	for datacenter, dcRegion := range dcRegionMap {
+		if strings.EqualFold(region, dcRegion) {
+			out = append(out, dcServerMap[datacenter]...)
+		}
+	}
+	return out
+}
+
+func sliceContains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
 }
 
 func FFLogsCharacterURL(character Character) string {
+	region := GetServerRegion(character.Server)
+	if region == "" {
+		// fflogs character urls need the region, search instead
+		return fmt.Sprintf("https://www.fflogs.com/search/?term=%s", url.QueryEscape(character.Name+" "+character.Server))
+	}
 	return fmt.Sprintf(
 		"https://www.fflogs.com/character/%s/%s/%s",
-		GetServerRegion(character.Server),
+		region,
 		strings.ToLower(character.Server),
 		strings.ToLower(character.Name))
 }
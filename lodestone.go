@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var lodestoneCharacterURLRegex = regexp.MustCompile(`lodestone\/character\/([0-9]+)`)
+var lodestoneNameRegex = regexp.MustCompile(`(?s)<p class="frame__chara__name">(.*?)</p>`)
+var lodestoneWorldRegex = regexp.MustCompile(`(?s)<p class="frame__chara__world">(?:<i[^>]*></i>)?\s*([^\s<\[]+)`)
+var lodestoneBioRegex = regexp.MustCompile(`(?s)<div class="character__selfintroduction">(.*?)</div>`)
+
+// LodestoneCharacter is a character profile read from the Lodestone.
+type LodestoneCharacter struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	Bio    string `json:"bio"`
+}
+
+// LodestoneFetcher fetches character profiles from the Lodestone.
+type LodestoneFetcher interface {
+	FetchCharacter(ctx context.Context, lodestoneID int64) (LodestoneCharacter, error)
+}
+
+// NewLodestoneFetcher returns the lodestone fetcher for the config, profiles are read from a fixture file when one is set.
+func NewLodestoneFetcher(config *Config) LodestoneFetcher {
+	if config.LodestoneFixtureFile != "" {
+		return &fakeLodestoneFetcher{path: config.LodestoneFixtureFile}
+	}
+	return &httpLodestoneFetcher{
+		httpClient: &http.Client{Timeout: time.Second * 15},
+		baseURL:    config.LodestoneBaseURL,
+	}
+}
+
+// LodestoneIDFromURL returns the character id from a Lodestone character url or a plain id, zero if invalid.
+func LodestoneIDFromURL(lodestoneURL string) int64 {
+	lodestoneURL = strings.TrimSpace(lodestoneURL)
+	if results := lodestoneCharacterURLRegex.FindStringSubmatch(lodestoneURL); len(results) > 1 {
+		lodestoneURL = results[1]
+	}
+	lodestoneID, err := strconv.ParseInt(lodestoneURL, 10, 64)
+	if err != nil || lodestoneID <= 0 {
+		return 0
+	}
+	return lodestoneID
+}
+
+// httpLodestoneFetcher scrapes character profiles from the Lodestone website.
+type httpLodestoneFetcher struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func (f *httpLodestoneFetcher) FetchCharacter(ctx context.Context, lodestoneID int64) (LodestoneCharacter, error) {
+	out := LodestoneCharacter{ID: lodestoneID}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/lodestone/character/%d/", f.baseURL, lodestoneID), nil)
+	if err != nil {
+		return out, err
+	}
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return out, ErrLodestoneCharacterNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return out, fmt.Errorf("lodestone responded with status %d", resp.StatusCode)
+	}
+	rawPage, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return out, err
+	}
+	return parseLodestoneCharacterPage(lodestoneID, string(rawPage))
+}
+
+func parseLodestoneCharacterPage(lodestoneID int64, page string) (LodestoneCharacter, error) {
+	out := LodestoneCharacter{ID: lodestoneID}
+	name := lodestoneNameRegex.FindStringSubmatch(page)
+	world := lodestoneWorldRegex.FindStringSubmatch(page)
+	if len(name) < 2 || len(world) < 2 {
+		return out, ErrLodestoneCharacterNotFound
+	}
+	out.Name = html.UnescapeString(strings.TrimSpace(name[1]))
+	out.Server = strings.TrimSpace(world[1])
+	if bio := lodestoneBioRegex.FindStringSubmatch(page); len(bio) > 1 {
+		out.Bio = html.UnescapeString(bio[1])
+	}
+	return out, nil
+}
+
+// fakeLodestoneFetcher reads character profiles from a json file mapping lodestone ids to profiles, for offline testing.
+type fakeLodestoneFetcher struct {
+	path string
+}
+
+func (f *fakeLodestoneFetcher) FetchCharacter(ctx context.Context, lodestoneID int64) (LodestoneCharacter, error) {
+	characters := map[string]LodestoneCharacter{}
+	rawData, err := os.ReadFile(f.path)
+	if err != nil {
+		return LodestoneCharacter{}, err
+	}
+	if err := json.Unmarshal(rawData, &characters); err != nil {
+		return LodestoneCharacter{}, err
+	}
+	character, ok := characters[strconv.FormatInt(lodestoneID, 10)]
+	if !ok {
+		return LodestoneCharacter{}, ErrLodestoneCharacterNotFound
+	}
+	character.ID = lodestoneID
+	return character, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	logger.Default = logger.Default.LogMode(logger.Silent)
+	if err := LoadDataMaps(); err != nil {
+		log.SetOutput(os.Stderr)
+		log.Fatalf("Error loading data mappings: %s\n", err.Error())
+	}
+	var err error
+	if htmlTemplates, err = getTemplates(); err != nil {
+		log.SetOutput(os.Stderr)
+		log.Fatalf("Error loading templates: %s\n", err.Error())
+	}
+	os.Exit(m.Run())
+}
+
+// newTestConfig returns a config that uses a fake fflogs server and an empty database in a temporary directory.
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	server := httptest.NewServer(NewFakeFFLogsHandler(fakeFFLogsFixtureDir))
+	t.Cleanup(server.Close)
+	return &Config{
+		FFLogsAPIVersion:   FFLogsAPIVersion1,
+		FFLogsApiKey:       "test",
+		FFLogsClientID:     "test",
+		FFLogsClientSecret: "test",
+		FFLogsBaseURL:      server.URL,
+		DatabaseFile:       filepath.Join(t.TempDir(), "test.db"),
+		ImportMaxAttempts:  1,
+		ImportWorkers:      1,
+		FFLogsRateLimit:    60000,
+		StorePulls:         true,
+	}
+}
+
+func newTestHandlers(t *testing.T, config *Config) (*DatabaseHandler, *FFLogsHandler) {
+	t.Helper()
+	db, err := NewDatabaserHandler(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.Conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	fflogHandler, err := NewFFLogsHandler(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fflogHandler
+}
+
+// importTestReport fetches a report from the fake fflogs server and saves it.
+func importTestReport(t *testing.T, db *DatabaseHandler, fflogHandler *FFLogsHandler, reportID string) Report {
+	t.Helper()
+	report, err := fetchAndSaveReport(db, fflogHandler, reportID)
+	if err != nil {
+		t.Fatalf("import %s: %s", reportID, err)
+	}
+	return report
+}
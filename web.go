@@ -5,7 +5,9 @@ import (
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tdewolff/minify/v2"
@@ -26,23 +28,149 @@ type importUserTrack struct {
 
 // importUserTracking is a list of all users who have imported reports
 var importUserTracking []*importUserTrack
+var importUserTrackingLock sync.Mutex
 
 // displayEncounterData contains data to display encounter data
 type displayEncounterData struct {
-	Category   string
-	Encounters []EncounterInfo
+	Category   string          `json:"category"`
+	Encounters []EncounterInfo `json:"encounters"`
 }
 
+// progressionChartPoint is a single point on a progression chart
+type progressionChartPoint struct {
+	X     int
+	Y     int
+	Label string
+}
+
+// progressionChart contains data to draw best progress over time as an svg line chart
+type progressionChart struct {
+	Width  int
+	Height int
+	Points []progressionChartPoint
+	Line   string
+}
+
+// leaderboardSize is the number of entries displayed on a leaderboard
+const leaderboardSize = 100
+
+// leaderboardData contains a leaderboard along with the filters used to build it
+type leaderboardData struct {
+	Type        string             `json:"type"`
+	Region      string             `json:"region"`
+	DataCenter  string             `json:"data_center"`
+	Job         string             `json:"job"`
+	Release     time.Time          `json:"release"`
+	Entries     []LeaderboardEntry `json:"entries"`
+	Regions     []string           `json:"-"`
+	DataCenters []string           `json:"-"`
+	Jobs        []string           `json:"-"`
+}
+
+// staticMaxNameLength is the maximum length of a static name
+const staticMaxNameLength = 32
+
 // templateData Struct containing data to be made available to html template
 type templateData struct {
 	AppName              string
 	VersionString        string
 	Characters           []Character
 	CharacterProgression []CharacterProgression
+	Reports              map[string]Report
+	Stats                map[uint]CharacterEncounterStats
+	JobClears            map[uint][]string
+	Aliases              []CharacterAlias
 	EncounterList        []displayEncounterData
+	ImportJob            ImportJob
+	Statics              []Static
+	StaticProgression    []StaticProgression
+	StaticHistory        []StaticProgression
+	Encounter            EncounterInfo
+	Leaderboard          leaderboardData
+	Distribution         EncounterDistribution
+	Browse               browseData
+	Owner                characterOwnerData
+	StaticEditable       bool
+	Admin                adminData
+	Chart                progressionChart
 	Message              string
 }
 
+// newProgressionChart plots the best fight progress reached over time from a list of progressions sorted by time.
+func newProgressionChart(characterProgressions []CharacterProgression) progressionChart {
+	chart := progressionChart{
+		Width:  600,
+		Height: 200,
+		Points: make([]progressionChartPoint, 0, len(characterProgressions)),
+	}
+	if len(characterProgressions) == 0 {
+		return chart
+	}
+	start := characterProgressions[0].Time.Unix()
+	span := characterProgressions[len(characterProgressions)-1].Time.Unix() - start
+	bestPercent := int64(10000)
+	linePoints := make([]string, 0)
+	for _, characterProgression := range characterProgressions {
+		percent := characterProgression.FightPercentage
+		if characterProgression.IsKill {
+			percent = 0
+		}
+		if percent < bestPercent {
+			bestPercent = percent
+		}
+		x := chart.Width / 2
+		if span > 0 {
+			x = int((characterProgression.Time.Unix() - start) * int64(chart.Width) / span)
+		}
+		y := int(bestPercent * int64(chart.Height) / 10000)
+		// step to the new best so the line only changes when progress is made
+		if len(chart.Points) > 0 {
+			linePoints = append(linePoints, fmt.Sprintf("%d,%d", x, chart.Points[len(chart.Points)-1].Y))
+		}
+		linePoints = append(linePoints, fmt.Sprintf("%d,%d", x, y))
+		chart.Points = append(chart.Points, progressionChartPoint{
+			X:     x,
+			Y:     y,
+			Label: fmt.Sprintf("%s - %.2f%%", characterProgression.Time.Format("2006-01-02"), float32(bestPercent)/100.0),
+		})
+	}
+	chart.Line = strings.Join(linePoints, " ")
+	return chart
+}
+
+// newClearsChart plots the total number of characters that have cleared an encounter over time.
+func newClearsChart(clearDays []EncounterClearDay) progressionChart {
+	chart := progressionChart{
+		Width:  600,
+		Height: 200,
+		Points: make([]progressionChartPoint, 0, len(clearDays)),
+	}
+	if len(clearDays) == 0 {
+		return chart
+	}
+	start, _ := time.Parse("2006-01-02", clearDays[0].Day)
+	end, _ := time.Parse("2006-01-02", clearDays[len(clearDays)-1].Day)
+	span := end.Unix() - start.Unix()
+	maxTotal := clearDays[len(clearDays)-1].Total
+	linePoints := make([]string, 0)
+	for _, clearDay := range clearDays {
+		day, _ := time.Parse("2006-01-02", clearDay.Day)
+		x := chart.Width / 2
+		if span > 0 {
+			x = int((day.Unix() - start.Unix()) * int64(chart.Width) / span)
+		}
+		y := chart.Height - int(clearDay.Total*int64(chart.Height)/maxTotal)
+		linePoints = append(linePoints, fmt.Sprintf("%d,%d", x, y))
+		chart.Points = append(chart.Points, progressionChartPoint{
+			X:     x,
+			Y:     y,
+			Label: fmt.Sprintf("%s - %d clears", clearDay.Day, clearDay.Total),
+		})
+	}
+	chart.Line = strings.Join(linePoints, " ")
+	return chart
+}
+
 func getTemplates() (map[string]*template.Template, error) {
 	// create template map
 	var templates = make(map[string]*template.Template)
@@ -71,6 +199,18 @@ func getTemplates() (map[string]*template.Template, error) {
 			return fmt.Sprintf("%02d:%02d", (d/1000)/60, (d/1000)%60)
 		},
 		"fflogurl": FFLogsCharacterURL,
+		"job":      strings.ToUpper,
+		"jobrole":  JobRole,
+		"worldurl": browseWorldURL,
+		"hours": func(d int64) string {
+			return fmt.Sprintf("%.1f", float64(d)/float64(time.Hour/time.Millisecond))
+		},
+		"add": func(a int, b int) int {
+			return a + b
+		},
+		"days": func(d int64) string {
+			return fmt.Sprintf("%.1f", float64(d)/float64(24*time.Hour/time.Millisecond))
+		},
 	}
 	// make layout templates
 	for _, layoutFile := range layoutFiles {
@@ -107,6 +247,133 @@ func displayAjaxMessage(w http.ResponseWriter, message string, statusCode int) {
 	htmlTemplates["ajax_message.tmpl"].ExecuteTemplate(w, "blank.tmpl", td)
 }
 
+func displayImportStatus(w http.ResponseWriter, importJob ImportJob, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	td := getBaseTemplateData()
+	td.ImportJob = importJob
+	td.Message = message
+	htmlTemplates["import_status.tmpl"].ExecuteTemplate(w, "blank.tmpl", td)
+}
+
+// visibleCharacters returns the given characters without those that are hidden.
+func visibleCharacters(characters []Character) []Character {
+	out := make([]Character, 0, len(characters))
+	for _, character := range characters {
+		if !character.Hidden {
+			out = append(out, character)
+		}
+	}
+	return out
+}
+
+// fetchLeaderboard builds the leaderboard for an encounter using the filters given in the request.
+func fetchLeaderboard(r *http.Request, config *Config, db *DatabaseHandler, encounter EncounterInfo) (leaderboardData, error) {
+	leaderboard := leaderboardData{
+		Type:        r.URL.Query().Get("type"),
+		Region:      strings.ToLower(strings.TrimSpace(r.URL.Query().Get("region"))),
+		DataCenter:  strings.TrimSpace(r.URL.Query().Get("dc")),
+		Job:         NormalizeJob(strings.TrimSpace(r.URL.Query().Get("job"))),
+		Regions:     GetRegions(),
+		DataCenters: GetDataCenters(),
+	}
+	if leaderboard.Type != LeaderboardFastestKill {
+		leaderboard.Type = LeaderboardEarliestClear
+	}
+	var servers []string
+	if leaderboard.DataCenter != "" {
+		servers = GetDataCenterServers(leaderboard.DataCenter)
+		if servers == nil {
+			return leaderboard, ErrUnknownDataCenter
+		}
+	} else if leaderboard.Region != "" {
+		servers = GetRegionServers(leaderboard.Region)
+		if servers == nil {
+			return leaderboard, ErrUnknownRegion
+		}
+	}
+	var err error
+	leaderboard.Jobs, err = db.FetchEncounterJobs(encounter.ID)
+	if err != nil {
+		return leaderboard, err
+	}
+	// without a known release date the first recorded pull is used
+	release, ok := config.EncounterReleaseDate(encounter.BossID)
+	if !ok {
+		release, err = db.FetchEncounterFirstTime(encounter.ID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return leaderboard, err
+		}
+	}
+	leaderboard.Release = release
+	leaderboard.Entries, err = db.FetchLeaderboard(encounter.ID, leaderboard.Type, servers, leaderboard.Job, leaderboardSize)
+	if err != nil {
+		return leaderboard, err
+	}
+	for i := range leaderboard.Entries {
+		leaderboard.Entries[i].SinceRelease = leaderboard.Entries[i].Time.Sub(release).Milliseconds()
+	}
+	return leaderboard, nil
+}
+
+// submitImport adds the report given in the request to the import queue, rate limited per client.
+// Returns the report id along with a message and status code to display.
+func submitImport(r *http.Request, config *Config, db *DatabaseHandler, fflogsImportQueue *FFLogsImportQueue) (string, string, int) {
+	userIPAddress := ReadUserIP(r)
+	if userIPAddress == "" {
+		return "", "Invalid client.", 400
+	}
+	importUserTrackingLock.Lock()
+	var importTrack *importUserTrack = nil
+	for _, it := range importUserTracking {
+		if it.IPAddress == userIPAddress {
+			importTrack = it
+			break
+		}
+	}
+	if importTrack == nil {
+		importTrack = &importUserTrack{
+			IPAddress: userIPAddress,
+			Limiter:   rate.NewLimiter(0.1, 1),
+		}
+		importUserTracking = append(importUserTracking, importTrack)
+	}
+	importUserTrackingLock.Unlock()
+	if !importTrack.Limiter.Allow() {
+		return "", "Too many import request sent, please wait a little bit.", http.StatusTooManyRequests
+	}
+	reportID := FFLogReportURLToReportID(r.FormValue("r"))
+	if reportID == "" {
+		return "", "FFLogs report URL not provided or invalid.", 400
+	}
+	message := "Your report is being processed."
+	if report, err := db.FetchReportFromReportID(reportID); err == nil {
+		// live reports may be refreshed to pick up new pulls
+		if !report.CanRefresh(config.ReportLiveWindowDuration(), config.ReportRefreshCooldownDuration()) {
+			if report.IsLive(config.ReportLiveWindowDuration()) {
+				return reportID, fmt.Sprintf("FFLogs report %s was recently processed, please wait a little before refreshing it.", reportID), 400
+			}
+			return reportID, fmt.Sprintf("FFLogs report %s has already been processed.", reportID), 400
+		}
+		message = "Your report is being refreshed."
+	} else if db.HasFFLogsReport(reportID) {
+		return reportID, fmt.Sprintf("FFLogs report %s has already been processed.", reportID), 400
+	}
+	if err := fflogsImportQueue.Add(reportID, userIPAddress); err != nil {
+		if err != ErrAlreadyInQueue {
+			return reportID, fmt.Sprintf("An Error Occured: %s", err.Error()), 500
+		}
+		message = fmt.Sprintf("FFLogs report %s is already being processed.", reportID)
+	}
+	return reportID, message, http.StatusOK
+}
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("text/html", html.Minify)
+	return m
+}
+
 func StartWeb(config *Config) error {
 
 	var err error
@@ -123,17 +390,20 @@ func StartWeb(config *Config) error {
 		return err
 	}
 
-	// init import queue
-	fflogsImportQueue, err := NewFFLogsImportQueue(config, db)
+	// init import queue and report discovery, sharing the fflogs rate limit
+	fflogHandler, err := NewFFLogsHandler(config)
+	if err != nil {
+		return err
+	}
+	fflogsImportQueue, err := NewFFLogsImportQueue(config, db, fflogHandler)
 	if err != nil {
 		return err
 	}
-	go fflogsImportQueue.Start()
+	fflogsImportQueue.Start()
+	NewFFLogsReportCrawler(config, db, fflogHandler, fflogsImportQueue).Start()
 
 	// init minifier
-	m := minify.New()
-	m.AddFunc("text/css", css.Minify)
-	m.AddFunc("text/html", html.Minify)
+	m := newMinifier()
 
 	importUserTracking = make([]*importUserTrack, 0)
 	mux := http.NewServeMux()
@@ -143,6 +413,12 @@ func StartWeb(config *Config) error {
 	mux.Handle("/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		td := getBaseTemplateData()
+		encounterList, err := db.FetchEncounterList()
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.EncounterList = EncounterDisplayListFromEncounterInfoList(encounterList, config)
 		htmlTemplates["home.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
 	})))
 
@@ -179,67 +455,293 @@ func StartWeb(config *Config) error {
 		}
 		character, err := db.FetchCharacterFromUID(uid)
 		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayError(w, err.Error(), 404)
+				return
+			}
 			displayError(w, err.Error(), 500)
 			return
 		}
+		// linked characters redirect to the character that replaced them
+		if character.IsMerged() {
+			canonicalCharacter, err := db.FetchCanonicalCharacter(character)
+			if err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+			pathes[2] = canonicalCharacter.UID
+			http.Redirect(w, r, strings.Join(pathes, "/"), http.StatusMovedPermanently)
+			return
+		}
+		td.Characters = []Character{character}
+		td.Owner, err = fetchCharacterOwnerData(r, db, character)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		// hidden characters are only visible to their owner
+		if character.Hidden && !td.Owner.IsOwner {
+			displayError(w, gorm.ErrRecordNotFound.Error(), 404)
+			return
+		}
+		// progression history for a single encounter
+		if len(pathes) > 3 && pathes[3] != "" {
+			encounterID, err := strconv.Atoi(pathes[3])
+			if err != nil {
+				displayError(w, "encounter id is invalid", 400)
+				return
+			}
+			if td.Owner.HiddenEncounters[uint(encounterID)] && !td.Owner.IsOwner {
+				displayError(w, gorm.ErrRecordNotFound.Error(), 404)
+				return
+			}
+			td.Encounter, err = db.FetchEncounterInfo(uint(encounterID))
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					displayError(w, err.Error(), 404)
+					return
+				}
+				displayError(w, err.Error(), 500)
+				return
+			}
+			td.CharacterProgression, err = db.FetchCharacterProgressionsForEncounter(character.ID, td.Encounter.ID)
+			if err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+			td.Reports, err = db.FetchReportsForCharacterProgressions(td.CharacterProgression)
+			if err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+			td.Chart = newProgressionChart(td.CharacterProgression)
+			htmlTemplates["character_prog_details.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+			return
+		}
 		encounterList, err := db.FetchEncounterList()
 		if err != nil {
 			displayError(w, err.Error(), 500)
 			return
 		}
 		td.EncounterList = EncounterDisplayListFromEncounterInfoList(encounterList, config)
-		td.Characters = []Character{character}
 		characterProgress, err := db.FetchBestCharacterProgressions(character.ID)
 		if err != nil {
 			displayError(w, err.Error(), 500)
 			return
 		}
 		td.CharacterProgression = characterProgress
+		td.Reports, err = db.FetchReportsForCharacterProgressions(characterProgress)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Stats, err = db.FetchCharacterEncounterStats(character.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.JobClears, err = db.FetchCharacterJobClears(character.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Aliases, err = db.FetchCharacterAliases(character.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Statics, err = db.FetchStaticsForCharacter(character.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		hideCharacterEncounters(&td)
 		htmlTemplates["character_prog_list.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
 	})))
 
-	mux.Handle("/i/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/b/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		userIPAddress := ReadUserIP(r)
-		if userIPAddress == "" {
-			displayAjaxMessage(w, "Invalid client.", 400)
+		td := getBaseTemplateData()
+		pathes := make([]string, 0)
+		for _, path := range strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/b/"), "/"), "/") {
+			if path != "" {
+				pathes = append(pathes, path)
+			}
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		if page < 0 {
+			page = 0
 		}
-		var importTrack *importUserTrack = nil
-		for _, it := range importUserTracking {
-			if it.IPAddress == userIPAddress {
-				importTrack = it
-				break
+		td.Browse, err = fetchBrowseData(db, pathes, page)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayError(w, "unknown region, data center or world", 404)
+				return
 			}
+			displayError(w, err.Error(), 500)
+			return
 		}
-		if importTrack == nil {
-			importTrack = &importUserTrack{
-				IPAddress: userIPAddress,
-				Limiter:   rate.NewLimiter(0.1, 1),
+		htmlTemplates["browse.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})))
+
+	mux.Handle("/e/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		td := getBaseTemplateData()
+		encounterID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/e/"))
+		if err != nil {
+			displayError(w, "encounter id is invalid", 400)
+			return
+		}
+		td.Encounter, err = db.FetchEncounterInfo(uint(encounterID))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayError(w, err.Error(), 404)
+				return
 			}
-			importUserTracking = append(importUserTracking, importTrack)
+			displayError(w, err.Error(), 500)
+			return
 		}
-		if !importTrack.Limiter.Allow() {
-			displayAjaxMessage(w, "Too many import request sent, please wait a little bit.", http.StatusTooManyRequests)
+		td.Distribution, err = db.FetchEncounterDistribution(td.Encounter.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
 			return
 		}
-		reportID := FFLogReportURLToReportID(r.URL.Query().Get("r"))
-		if reportID == "" {
-			displayAjaxMessage(w, "FFLogs report URL not provided or invalid.", 400)
+		td.Chart = newClearsChart(td.Distribution.ClearsOverTime)
+		htmlTemplates["encounter.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})))
+
+	mux.Handle("/l/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		td := getBaseTemplateData()
+		encounterID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/l/"))
+		if err != nil {
+			displayError(w, "encounter id is invalid", 400)
+			return
+		}
+		td.Encounter, err = db.FetchEncounterInfo(uint(encounterID))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayError(w, err.Error(), 404)
+				return
+			}
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Leaderboard, err = fetchLeaderboard(r, config, db, td.Encounter)
+		if err != nil {
+			if err == ErrUnknownDataCenter || err == ErrUnknownRegion {
+				displayError(w, err.Error(), 400)
+				return
+			}
+			displayError(w, err.Error(), 500)
 			return
 		}
-		if db.HasFFLogsReport(reportID) {
-			displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s has already been processed.", reportID), 400)
+		htmlTemplates["leaderboard.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})))
+
+	mux.Handle("/t/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		td := getBaseTemplateData()
+		uid := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/t/")))
+		if uid == "" {
+			displayError(w, "static id is required", 400)
 			return
 		}
-		if err := fflogsImportQueue.Add(reportID); err != nil {
-			if err == ErrAlreadyInQueue {
-				displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s is already being processed.", reportID), 400)
+		static, err := db.FetchStaticFromUID(uid)
+		if err == nil && !static.IsRecurring() {
+			err = gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayError(w, err.Error(), 404)
+				return
+			}
+			displayError(w, err.Error(), 500)
+			return
+		}
+		// the static can be named by anyone that has verified one of its members
+		ownerKeyHash := readOwnerKeyHash(r)
+		if ownerKeyHash != "" {
+			td.StaticEditable, err = db.IsStaticMemberOwner(static.ID, ownerKeyHash)
+			if err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			name := strings.TrimSpace(r.FormValue("name"))
+			if !td.StaticEditable {
+				displayError(w, "only the verified owner of a static member can name the static", 403)
+				return
+			}
+			if name == "" || len(name) > staticMaxNameLength {
+				displayError(w, fmt.Sprintf("static name must be between 1 and %d characters", staticMaxNameLength), 400)
+				return
+			}
+			if err := db.ClaimStatic(&static, name, ownerKeyHash); err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+			http.Redirect(w, r, "/t/"+static.UID, http.StatusSeeOther)
+			return
+		}
+		encounterList, err := db.FetchEncounterList()
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.EncounterList = EncounterDisplayListFromEncounterInfoList(encounterList, config)
+		td.Statics = []Static{static}
+		td.Characters = visibleCharacters(static.Members)
+		td.StaticProgression, err = db.FetchBestStaticProgressions(static.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.StaticHistory, err = db.FetchStaticProgressions(static.ID)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		htmlTemplates["static.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})))
+
+	mux.Handle("/i/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		reportID, message, statusCode := submitImport(r, config, db, fflogsImportQueue)
+		if statusCode != http.StatusOK {
+			displayAjaxMessage(w, message, statusCode)
+			return
+		}
+		importJob, err := db.FetchImportJobFromReportID(reportID)
+		if err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		displayImportStatus(w, importJob, message)
+	})))
+
+	mux.Handle("/i/status/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reportID := FFLogReportURLToReportID(strings.TrimPrefix(r.URL.Path, "/i/status/"))
+		if reportID == "" {
+			displayAjaxMessage(w, "FFLogs report ID not provided or invalid.", 400)
+			return
+		}
+		importJob, err := db.FetchImportJobFromReportID(reportID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s has not been submitted.", reportID), 404)
 				return
 			}
 			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
 		}
-		displayAjaxMessage(w, "Your report is being processed.", 200)
+		displayImportStatus(w, importJob, "")
 	})))
 
+	registerAdminHandlers(mux, m, config, db, fflogsImportQueue)
+	registerClaimHandlers(mux, m, db, NewLodestoneFetcher(config))
+	registerAPIHandlers(mux, config, db, fflogsImportQueue)
+
 	return http.ListenAndServe(fmt.Sprintf(":%d", config.HTTPPort), mux)
 }
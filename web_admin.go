@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/minify/v2"
+)
+
+const adminListSize = 50
+const adminCSRFCookieName = "ffprog_admin_csrf"
+const adminCSRFHeaderName = "X-CSRF-Token"
+
+// adminData contains the state displayed on the admin console.
+type adminData struct {
+	Queue      []ImportJob
+	ImportJobs []ImportJob
+	Reports    []Report
+	Encounters []EncounterInfo
+	CSRFToken  string
+	StorePulls bool
+}
+
+// ensureAdminCSRFToken returns the csrf token of the admin console, a new token is issued if the request has none.
+func ensureAdminCSRFToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(adminCSRFCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	token := randomHex(32)
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCSRFCookieName,
+		Value:    token,
+		Path:     "/admin",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// checkAdminCSRFToken returns true if the request sends back the csrf token from its cookie.
+// Browsers resend basic auth logins on cross site requests, token requests are not sent automatically.
+func checkAdminCSRFToken(r *http.Request) bool {
+	if _, _, ok := r.BasicAuth(); !ok {
+		return true
+	}
+	cookie, err := r.Cookie(adminCSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	token := r.Header.Get(adminCSRFHeaderName)
+	if token == "" {
+		token = r.FormValue("csrf_token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
+
+// checkAdminPost writes an error and returns false unless the request is an authorized admin post.
+func checkAdminPost(w http.ResponseWriter, r *http.Request, config *Config) bool {
+	if !IsAdminRequest(r, config) {
+		displayAjaxMessage(w, "Unauthorized.", http.StatusUnauthorized)
+		return false
+	}
+	if r.Method != http.MethodPost {
+		displayAjaxMessage(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return false
+	}
+	if !checkAdminCSRFToken(r) {
+		displayAjaxMessage(w, "Invalid CSRF token, reload the admin console and try again.", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func registerAdminHandlers(mux *http.ServeMux, m *minify.M, config *Config, db *DatabaseHandler, fflogsImportQueue *FFLogsImportQueue) {
+
+	mux.Handle("/admin", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if !IsAdminRequest(r, config) {
+			// let the browser prompt for the basic auth login
+			if config.AdminUsername != "" && config.AdminPassword != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="ffprog admin"`)
+			}
+			displayError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		td := getBaseTemplateData()
+		var err error
+		td.Admin.CSRFToken = ensureAdminCSRFToken(w, r)
+		td.Admin.StorePulls = config.StorePulls
+		td.Admin.Queue = fflogsImportQueue.Jobs()
+		td.Admin.ImportJobs, err = db.FetchRecentImportJobs(adminListSize)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Admin.Reports, err = db.FetchRecentReports(adminListSize)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Admin.Encounters, err = db.FetchEncounterList()
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		htmlTemplates["admin.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})))
+
+	mux.Handle("/admin/queue", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		reportID := FFLogReportURLToReportID(r.FormValue("report"))
+		if reportID == "" {
+			displayAjaxMessage(w, "FFLogs report ID not provided or invalid.", 400)
+			return
+		}
+		var err error
+		message := ""
+		switch r.FormValue("action") {
+		case "remove":
+			err = fflogsImportQueue.Remove(reportID)
+			message = fmt.Sprintf("FFLogs report %s removed from queue.", reportID)
+		case "prioritize":
+			err = fflogsImportQueue.Prioritize(reportID)
+			message = fmt.Sprintf("FFLogs report %s moved to the front of the queue.", reportID)
+		default:
+			displayAjaxMessage(w, "Unknown action.", 400)
+			return
+		}
+		if err != nil {
+			if err == ErrNotInQueue || err == ErrImportJobBusy {
+				displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s: %s.", reportID, err.Error()), 400)
+				return
+			}
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		displayAjaxMessage(w, message, 200)
+	}))
+
+	mux.Handle("/admin/delete-report", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		reportID := FFLogReportURLToReportID(r.FormValue("report"))
+		if reportID == "" {
+			displayAjaxMessage(w, "FFLogs report ID not provided or invalid.", 400)
+			return
+		}
+		// a queued import would bring the report straight back
+		if err := fflogsImportQueue.Remove(reportID); err != nil && err != ErrNotInQueue {
+			displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s: %s.", reportID, err.Error()), 400)
+			return
+		}
+		if err := db.DeleteReport(reportID); err == ErrProgressionsNotRebuilt {
+			displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s deleted, but the %s.", reportID, err.Error()), 200)
+			return
+		} else if err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s deleted.", reportID), 200)
+	}))
+
+	mux.Handle("/admin/encounter", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		encounterID, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			displayAjaxMessage(w, "Encounter ID is invalid.", 400)
+			return
+		}
+		encounter, err := db.FetchEncounterInfo(uint(encounterID))
+		if err != nil {
+			displayAjaxMessage(w, err.Error(), 404)
+			return
+		}
+		displayName := strings.TrimSpace(r.FormValue("display_name"))
+		if err := db.SaveEncounterDisplayName(encounter.ID, displayName); err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		encounter.DisplayName = displayName
+		displayAjaxMessage(w, fmt.Sprintf("Encounter %d is now displayed as %s.", encounter.ID, encounter.Name()), 200)
+	}))
+
+	mux.Handle("/admin/reimport/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		reportID := FFLogReportURLToReportID(strings.TrimPrefix(r.URL.Path, "/admin/reimport/"))
+		if reportID == "" {
+			displayAjaxMessage(w, "FFLogs report ID not provided or invalid.", 400)
+			return
+		}
+		if err := fflogsImportQueue.Add(reportID, ReadUserIP(r)); err != nil {
+			if err == ErrAlreadyInQueue {
+				displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s is already being processed.", reportID), 400)
+				return
+			}
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		displayAjaxMessage(w, fmt.Sprintf("FFLogs report %s queued for re-import.", reportID), 200)
+	}))
+
+	mux.Handle("/admin/reload-data", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		if err := LoadDataMaps(); err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		displayAjaxMessage(w, "Data mappings reloaded.", 200)
+	}))
+
+	mux.Handle("/admin/merge", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		source, err := db.FetchCharacterFromUID(strings.ToLower(strings.TrimSpace(r.FormValue("source"))))
+		if err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("Source character: %s", err.Error()), 404)
+			return
+		}
+		target, err := db.FetchCharacterFromUID(strings.ToLower(strings.TrimSpace(r.FormValue("target"))))
+		if err == nil {
+			target, err = db.FetchCanonicalCharacter(target)
+		}
+		if err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("Target character: %s", err.Error()), 404)
+			return
+		}
+		if err := db.MergeCharacters(source, target); err != nil {
+			if err == ErrMergeSameCharacter {
+				displayAjaxMessage(w, err.Error(), 400)
+				return
+			}
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		displayAjaxMessage(w, fmt.Sprintf("Character %s merged in to %s.", source.UID, target.UID), 200)
+	}))
+
+	mux.Handle("/admin/hide", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		character, err := db.FetchCharacterFromUID(strings.ToLower(strings.TrimSpace(r.FormValue("uid"))))
+		if err == nil {
+			character, err = db.FetchCanonicalCharacter(character)
+		}
+		if err != nil {
+			displayAjaxMessage(w, err.Error(), 404)
+			return
+		}
+		hidden := r.FormValue("hidden") != "0" && r.FormValue("hidden") != "false"
+		if err := db.SetCharacterHidden(character, hidden); err != nil {
+			displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+			return
+		}
+		if hidden {
+			displayAjaxMessage(w, fmt.Sprintf("Character %s hidden.", character.UID), 200)
+			return
+		}
+		displayAjaxMessage(w, fmt.Sprintf("Character %s visible.", character.UID), 200)
+	}))
+
+	mux.Handle("/admin/static", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminPost(w, r, config) {
+			return
+		}
+		static, err := db.FetchStaticFromUID(strings.ToLower(strings.TrimSpace(r.FormValue("uid"))))
+		if err != nil {
+			displayAjaxMessage(w, err.Error(), 404)
+			return
+		}
+		switch r.FormValue("action") {
+		case "rename":
+			name := strings.TrimSpace(r.FormValue("name"))
+			if name == "" || len(name) > staticMaxNameLength {
+				displayAjaxMessage(w, fmt.Sprintf("Static name must be between 1 and %d characters.", staticMaxNameLength), 400)
+				return
+			}
+			if err := db.ClaimStatic(&static, name, "admin"); err != nil {
+				displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+				return
+			}
+			displayAjaxMessage(w, fmt.Sprintf("Static %s renamed to %s.", static.UID, static.Name), 200)
+		case "unclaim":
+			if err := db.UnclaimStatic(&static); err != nil {
+				displayAjaxMessage(w, fmt.Sprintf("An Error Occured: %s", err.Error()), 500)
+				return
+			}
+			displayAjaxMessage(w, fmt.Sprintf("Static %s unclaimed.", static.UID), 200)
+		default:
+			displayAjaxMessage(w, "Unknown action.", 400)
+		}
+	}))
+
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminPostCSRF(t *testing.T) {
+	config := newTestConfig(t)
+	config.AdminToken = "token"
+	config.AdminUsername = "admin"
+	config.AdminPassword = "password"
+	db, fflogHandler := newTestHandlers(t, config)
+	queue, err := NewFFLogsImportQueue(config, db, fflogHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	registerAdminHandlers(mux, newMinifier(), config, db, queue)
+
+	// the console issues the token used by its forms
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.SetBasicAuth(config.AdminUsername, config.AdminPassword)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != adminCSRFCookieName {
+		t.Fatalf("admin console responded %d with cookies %v", rec.Code, cookies)
+	}
+	if !strings.Contains(rec.Body.String(), cookies[0].Value) {
+		t.Errorf("admin console does not contain the csrf token")
+	}
+	for _, tt := range []struct {
+		Name       string
+		BasicAuth  bool
+		Bearer     string
+		Cookie     string
+		Header     string
+		StatusCode int
+	}{
+		{"no login", false, "", "", "", http.StatusUnauthorized},
+		{"login without token", true, "", "", "", http.StatusForbidden},
+		{"login with cookie only", true, "", "csrf", "", http.StatusForbidden},
+		{"login with wrong token", true, "", "csrf", "other", http.StatusForbidden},
+		{"login with token", true, "", "csrf", "csrf", http.StatusOK},
+		{"admin token", false, "token", "", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload-data", nil)
+		if tt.BasicAuth {
+			req.SetBasicAuth(config.AdminUsername, config.AdminPassword)
+		}
+		if tt.Bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.Bearer)
+		}
+		if tt.Cookie != "" {
+			req.AddCookie(&http.Cookie{Name: adminCSRFCookieName, Value: tt.Cookie})
+		}
+		if tt.Header != "" {
+			req.Header.Set(adminCSRFHeaderName, tt.Header)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.StatusCode {
+			t.Errorf("%s: status = %d, want %d", tt.Name, rec.Code, tt.StatusCode)
+		}
+	}
+}
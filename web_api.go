@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const apiPrefix = "/api/v1/"
+
+// apiError is the response body for failed api requests.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiCharacterResponse struct {
+	Character    Character                 `json:"character"`
+	Progressions []CharacterProgression    `json:"progressions"`
+	Reports      map[string]Report         `json:"reports"`
+	Stats        []CharacterEncounterStats `json:"stats"`
+	JobClears    map[uint][]string         `json:"job_clears"`
+	Aliases      []CharacterAlias          `json:"aliases"`
+}
+
+type apiStaticResponse struct {
+	Static       Static              `json:"static"`
+	Progressions []StaticProgression `json:"progressions"`
+	History      []StaticProgression `json:"history"`
+}
+
+type apiEncounterResponse struct {
+	Encounter    EncounterInfo         `json:"encounter"`
+	Distribution EncounterDistribution `json:"distribution"`
+}
+
+type apiLeaderboardResponse struct {
+	Encounter   EncounterInfo   `json:"encounter"`
+	Leaderboard leaderboardData `json:"leaderboard"`
+}
+
+type apiEncounterListResponse struct {
+	Encounters []EncounterInfo        `json:"encounters"`
+	Categories []displayEncounterData `json:"categories"`
+}
+
+type apiImportResponse struct {
+	ReportID string    `json:"report_id"`
+	Message  string    `json:"message"`
+	Job      ImportJob `json:"job"`
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeJSONError(w http.ResponseWriter, err error, statusCode int) {
+	if err == gorm.ErrRecordNotFound {
+		statusCode = http.StatusNotFound
+	}
+	writeJSON(w, apiError{Error: err.Error()}, statusCode)
+}
+
+// apiPathParam returns the path segment that follows the given api route.
+func apiPathParam(r *http.Request, route string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix+route), "/"))
+}
+
+func registerAPIHandlers(mux *http.ServeMux, config *Config, db *DatabaseHandler, fflogsImportQueue *FFLogsImportQueue) {
+
+	mux.HandleFunc(apiPrefix+"characters", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSpace(r.URL.Query().Get("n"))
+		if name == "" {
+			writeJSONError(w, errors.New("character name is required"), 400)
+			return
+		}
+		characters, err := db.FindCharacters(name)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, characters, 200)
+	})
+
+	mux.HandleFunc(apiPrefix+"characters/", func(w http.ResponseWriter, r *http.Request) {
+		uid := strings.ToLower(apiPathParam(r, "characters/"))
+		if uid == "" {
+			writeJSONError(w, errors.New("character id is required"), 400)
+			return
+		}
+		character, err := db.FetchCharacterFromUID(uid)
+		if err == nil && character.Hidden {
+			err = gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		if character.IsMerged() {
+			canonicalCharacter, err := db.FetchCanonicalCharacter(character)
+			if err != nil {
+				writeJSONError(w, err, 500)
+				return
+			}
+			http.Redirect(w, r, apiPrefix+"characters/"+canonicalCharacter.UID, http.StatusMovedPermanently)
+			return
+		}
+		out := apiCharacterResponse{Character: character}
+		out.Progressions, err = db.FetchBestCharacterProgressions(character.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		out.Reports, err = db.FetchReportsForCharacterProgressions(out.Progressions)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		stats, err := db.FetchCharacterEncounterStats(character.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		out.JobClears, err = db.FetchCharacterJobClears(character.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		out.Aliases, err = db.FetchCharacterAliases(character.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		// encounters hidden by the owner are left out
+		hiddenEncounters, err := db.FetchCharacterHiddenEncounters(character.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		progressions := make([]CharacterProgression, 0, len(out.Progressions))
+		for _, prog := range out.Progressions {
+			if !hiddenEncounters[prog.EncounterInfoID] {
+				progressions = append(progressions, prog)
+			}
+		}
+		out.Progressions = progressions
+		for encounterID := range hiddenEncounters {
+			delete(out.JobClears, encounterID)
+		}
+		out.Stats = make([]CharacterEncounterStats, 0, len(stats))
+		for _, encounterStats := range stats {
+			if !hiddenEncounters[encounterStats.EncounterInfoID] {
+				out.Stats = append(out.Stats, encounterStats)
+			}
+		}
+		sort.Slice(out.Stats, func(i, j int) bool {
+			return out.Stats[i].EncounterInfoID < out.Stats[j].EncounterInfoID
+		})
+		writeJSON(w, out, 200)
+	})
+
+	mux.HandleFunc(apiPrefix+"statics/", func(w http.ResponseWriter, r *http.Request) {
+		uid := strings.ToLower(apiPathParam(r, "statics/"))
+		if uid == "" {
+			writeJSONError(w, errors.New("static id is required"), 400)
+			return
+		}
+		static, err := db.FetchStaticFromUID(uid)
+		if err == nil && !static.IsRecurring() {
+			err = gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		static.Members = visibleCharacters(static.Members)
+		out := apiStaticResponse{Static: static}
+		out.Progressions, err = db.FetchBestStaticProgressions(static.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		out.History, err = db.FetchStaticProgressions(static.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, out, 200)
+	})
+
+	mux.HandleFunc(apiPrefix+"encounters/", func(w http.ResponseWriter, r *http.Request) {
+		encounterID, err := strconv.Atoi(apiPathParam(r, "encounters/"))
+		if err != nil {
+			writeJSONError(w, errors.New("encounter id is invalid"), 400)
+			return
+		}
+		encounter, err := db.FetchEncounterInfo(uint(encounterID))
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		distribution, err := db.FetchEncounterDistribution(encounter.ID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, apiEncounterResponse{Encounter: encounter, Distribution: distribution}, 200)
+	})
+
+	mux.HandleFunc(apiPrefix+"leaderboards/", func(w http.ResponseWriter, r *http.Request) {
+		encounterID, err := strconv.Atoi(apiPathParam(r, "leaderboards/"))
+		if err != nil {
+			writeJSONError(w, errors.New("encounter id is invalid"), 400)
+			return
+		}
+		encounter, err := db.FetchEncounterInfo(uint(encounterID))
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		leaderboard, err := fetchLeaderboard(r, config, db, encounter)
+		if err != nil {
+			if err == ErrUnknownDataCenter || err == ErrUnknownRegion {
+				writeJSONError(w, err, 400)
+				return
+			}
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, apiLeaderboardResponse{Encounter: encounter, Leaderboard: leaderboard}, 200)
+	})
+
+	mux.HandleFunc(apiPrefix+"encounters", func(w http.ResponseWriter, r *http.Request) {
+		encounterList, err := db.FetchEncounterList()
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, apiEncounterListResponse{
+			Encounters: encounterList,
+			Categories: EncounterDisplayListFromEncounterInfoList(encounterList, config),
+		}, 200)
+	})
+
+	mux.HandleFunc(apiPrefix+"import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, errors.New("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+		reportID, message, statusCode := submitImport(r, config, db, fflogsImportQueue)
+		if statusCode != http.StatusOK {
+			writeJSONError(w, errors.New(message), statusCode)
+			return
+		}
+		importJob, err := db.FetchImportJobFromReportID(reportID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, apiImportResponse{ReportID: reportID, Message: message, Job: importJob}, http.StatusAccepted)
+	})
+
+	mux.HandleFunc(apiPrefix+"import/", func(w http.ResponseWriter, r *http.Request) {
+		reportID := FFLogReportURLToReportID(apiPathParam(r, "import/"))
+		if reportID == "" {
+			writeJSONError(w, errors.New("fflogs report id not provided or invalid"), 400)
+			return
+		}
+		importJob, err := db.FetchImportJobFromReportID(reportID)
+		if err != nil {
+			writeJSONError(w, err, 500)
+			return
+		}
+		writeJSON(w, importJob, 200)
+	})
+
+}
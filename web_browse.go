@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// browseUnknown is used in place of the region and data center of worlds missing from the data center map
+const browseUnknown = "unknown"
+
+// browsePageSize is the number of characters listed per page when browsing a world
+const browsePageSize = 100
+
+// browseLink is a link to a region, data center or world along with the number of characters in it
+type browseLink struct {
+	Name       string
+	URL        string
+	Characters int64
+}
+
+// browseData contains the region, data center or world being browsed
+type browseData struct {
+	Region     string
+	DataCenter string
+	World      string
+	Links      []browseLink
+	Characters []Character
+	Clears     map[uint][]EncounterInfo
+	Page       int
+	HasNext    bool
+}
+
+func browseURL(pathes ...string) string {
+	for i := range pathes {
+		pathes[i] = url.PathEscape(pathes[i])
+	}
+	return "/b/" + strings.Join(pathes, "/")
+}
+
+// browseWorldURL returns the browse url for a world.
+func browseWorldURL(server string) string {
+	dataCenter := GetServerDataCenter(server)
+	if dataCenter == "" {
+		return browseURL(browseUnknown, browseUnknown, server)
+	}
+	return browseURL(GetDataCenterRegion(dataCenter), dataCenter, server)
+}
+
+// unknownWorldLinks returns links to every world with tracked characters that isn't in the data center map.
+func unknownWorldLinks(serverCounts map[string]int64) []browseLink {
+	out := make([]browseLink, 0)
+	for server, count := range serverCounts {
+		if GetServerDataCenter(server) == "" {
+			out = append(out, browseLink{Name: server, URL: browseURL(browseUnknown, browseUnknown, server), Characters: count})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func sumBrowseLinks(links []browseLink) int64 {
+	total := int64(0)
+	for _, link := range links {
+		total += link.Characters
+	}
+	return total
+}
+
+// worldLinks returns links to every world in a data center.
+func worldLinks(region string, dataCenter string, serverCounts map[string]int64) []browseLink {
+	out := make([]browseLink, 0)
+	for _, server := range GetDataCenterServers(dataCenter) {
+		out = append(out, browseLink{Name: server, URL: browseURL(region, dataCenter, server), Characters: serverCounts[server]})
+	}
+	return out
+}
+
+// dataCenterLinks returns links to every data center in a region.
+func dataCenterLinks(region string, serverCounts map[string]int64) []browseLink {
+	out := make([]browseLink, 0)
+	for _, dataCenter := range GetRegionDataCenters(region) {
+		out = append(out, browseLink{Name: dataCenter, URL: browseURL(region, dataCenter), Characters: sumBrowseLinks(worldLinks(region, dataCenter, serverCounts))})
+	}
+	return out
+}
+
+// fetchBrowseData builds the listing for the given region, data center and world path.
+// Returns gorm.ErrRecordNotFound when any part of the path is unknown.
+func fetchBrowseData(db *DatabaseHandler, pathes []string, page int) (browseData, error) {
+	out := browseData{Page: page}
+	serverCounts, err := db.FetchServerCharacterCounts()
+	if err != nil {
+		return out, err
+	}
+	if len(pathes) == 0 {
+		out.Links = make([]browseLink, 0)
+		for _, region := range GetRegions() {
+			out.Links = append(out.Links, browseLink{Name: strings.ToUpper(region), URL: browseURL(region), Characters: sumBrowseLinks(dataCenterLinks(region, serverCounts))})
+		}
+		if unknownLinks := unknownWorldLinks(serverCounts); len(unknownLinks) > 0 {
+			out.Links = append(out.Links, browseLink{Name: "Unknown", URL: browseURL(browseUnknown), Characters: sumBrowseLinks(unknownLinks)})
+		}
+		return out, nil
+	}
+	out.Region = strings.ToLower(pathes[0])
+	if out.Region != browseUnknown && !sliceContains(GetRegions(), out.Region) {
+		return out, gorm.ErrRecordNotFound
+	}
+	if len(pathes) == 1 {
+		if out.Region == browseUnknown {
+			out.Links = unknownWorldLinks(serverCounts)
+			return out, nil
+		}
+		out.Links = dataCenterLinks(out.Region, serverCounts)
+		return out, nil
+	}
+	out.DataCenter = pathes[1]
+	if out.Region == browseUnknown {
+		if out.DataCenter != browseUnknown {
+			return out, gorm.ErrRecordNotFound
+		}
+	} else if !strings.EqualFold(GetDataCenterRegion(out.DataCenter), out.Region) {
+		return out, gorm.ErrRecordNotFound
+	}
+	if len(pathes) == 2 {
+		if out.Region == browseUnknown {
+			out.Links = unknownWorldLinks(serverCounts)
+			return out, nil
+		}
+		out.Links = worldLinks(out.Region, out.DataCenter, serverCounts)
+		return out, nil
+	}
+	out.World = pathes[2]
+	if out.Region == browseUnknown {
+		if GetServerDataCenter(out.World) != "" {
+			return out, gorm.ErrRecordNotFound
+		}
+	} else if !strings.EqualFold(GetServerDataCenter(out.World), out.DataCenter) {
+		return out, gorm.ErrRecordNotFound
+	}
+	// fetch one extra character to know if there is another page
+	out.Characters, err = db.FetchCharactersForServer(out.World, browsePageSize+1, page*browsePageSize)
+	if err != nil {
+		return out, err
+	}
+	if len(out.Characters) > browsePageSize {
+		out.HasNext = true
+		out.Characters = out.Characters[:browsePageSize]
+	}
+	characterIDs := make([]uint, 0, len(out.Characters))
+	for _, character := range out.Characters {
+		characterIDs = append(characterIDs, character.ID)
+	}
+	out.Clears, err = db.FetchCharacterClears(characterIDs)
+	return out, err
+}
+
+// Title returns the name of the region, data center or world being browsed.
+func (b browseData) Title() string {
+	switch {
+	case b.World != "":
+		return b.World
+	case b.DataCenter != "" && b.DataCenter != browseUnknown:
+		return b.DataCenter
+	case b.Region == browseUnknown:
+		return "Unknown Worlds"
+	case b.Region != "":
+		return strings.ToUpper(b.Region)
+	}
+	return "All Regions"
+}
+
+// Breadcrumbs returns links to the parents of the region, data center or world being browsed.
+func (b browseData) Breadcrumbs() []browseLink {
+	if b.Region == "" {
+		return nil
+	}
+	out := []browseLink{{Name: "All Regions", URL: browseURL()}}
+	if b.Region != "" && (b.DataCenter != "" || b.World != "") {
+		out = append(out, browseLink{Name: strings.ToUpper(b.Region), URL: browseURL(b.Region)})
+	}
+	if b.World != "" && b.DataCenter != browseUnknown {
+		out = append(out, browseLink{Name: b.DataCenter, URL: browseURL(b.Region, b.DataCenter)})
+	}
+	return out
+}
+
+func (b browseData) NextURL() string {
+	return fmt.Sprintf("%s?p=%d", browseURL(b.Region, b.DataCenter, b.World), b.Page+1)
+}
+
+func (b browseData) PrevURL() string {
+	return fmt.Sprintf("%s?p=%d", browseURL(b.Region, b.DataCenter, b.World), b.Page-1)
+}
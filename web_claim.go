@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tdewolff/minify/v2"
+	"gorm.io/gorm"
+)
+
+const ownerCookieName = "ffprog_owner"
+const ownerCookieMaxAge = 86400 * 365 * 5
+const claimTokenPrefix = "ffprog-"
+const lodestoneTimeout = time.Second * 20
+
+// characterOwnerData contains the ownership details of a character.
+type characterOwnerData struct {
+	Verified         bool
+	IsOwner          bool
+	LodestoneID      int64
+	Claim            CharacterClaim
+	HiddenEncounters map[uint]bool
+	Jobs             []string
+	Main             *Character
+	Alts             []Character
+	OwnedCharacters  []Character
+}
+
+// randomHex returns a random hex string from the given number of bytes.
+func randomHex(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hashOwnerKey(ownerKey string) string {
+	hash := sha256.Sum256([]byte(ownerKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// readOwnerKeyHash returns the hash of the owner key in the request cookie, empty if there is none.
+func readOwnerKeyHash(r *http.Request) string {
+	cookie, err := r.Cookie(ownerCookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	return hashOwnerKey(cookie.Value)
+}
+
+// ensureOwnerKeyHash returns the hash of the request owner key, a new key is issued if the request has none.
+func ensureOwnerKeyHash(w http.ResponseWriter, r *http.Request) string {
+	if ownerKeyHash := readOwnerKeyHash(r); ownerKeyHash != "" {
+		return ownerKeyHash
+	}
+	ownerKey := randomHex(32)
+	http.SetCookie(w, &http.Cookie{
+		Name:     ownerCookieName,
+		Value:    ownerKey,
+		Path:     "/",
+		MaxAge:   ownerCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return hashOwnerKey(ownerKey)
+}
+
+func fetchCharacterOwnerData(r *http.Request, db *DatabaseHandler, character Character) (characterOwnerData, error) {
+	out := characterOwnerData{}
+	verifiedClaim, err := db.FetchVerifiedCharacterClaim(character.ID)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return out, err
+	}
+	if err == nil {
+		ownerKeyHash := readOwnerKeyHash(r)
+		out.Verified = true
+		out.LodestoneID = verifiedClaim.LodestoneID
+		out.IsOwner = ownerKeyHash != "" && verifiedClaim.OwnerKeyHash == ownerKeyHash
+	}
+	out.HiddenEncounters, err = db.FetchCharacterHiddenEncounters(character.ID)
+	if err != nil {
+		return out, err
+	}
+	if character.AltOfID != nil {
+		main, err := db.FetchCharacterFromID(*character.AltOfID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return out, err
+		}
+		if err == nil {
+			out.Main = &main
+		}
+	}
+	out.Alts, err = db.FetchCharacterAlts(character.ID)
+	if err != nil {
+		return out, err
+	}
+	if !out.IsOwner {
+		return out, nil
+	}
+	out.Jobs, err = db.FetchCharacterJobs(character.ID)
+	if err != nil {
+		return out, err
+	}
+	out.OwnedCharacters, err = db.FetchOwnedCharacters(verifiedClaim.OwnerKeyHash)
+	return out, err
+}
+
+// hideCharacterEncounters removes encounters the owner has hidden from the template data, unless viewed by the owner.
+func hideCharacterEncounters(td *templateData) {
+	if td.Owner.IsOwner || len(td.Owner.HiddenEncounters) == 0 {
+		return
+	}
+	characterProgression := make([]CharacterProgression, 0, len(td.CharacterProgression))
+	for _, prog := range td.CharacterProgression {
+		if !td.Owner.HiddenEncounters[prog.EncounterInfoID] {
+			characterProgression = append(characterProgression, prog)
+		}
+	}
+	td.CharacterProgression = characterProgression
+	for encounterID := range td.Owner.HiddenEncounters {
+		delete(td.Stats, encounterID)
+		delete(td.JobClears, encounterID)
+	}
+}
+
+// verifyCharacterClaim checks the lodestone profile matches the character and contains the claim token.
+func verifyCharacterClaim(ctx context.Context, lodestone LodestoneFetcher, character Character, characterClaim CharacterClaim, lodestoneID int64) error {
+	lodestoneCharacter, err := lodestone.FetchCharacter(ctx, lodestoneID)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(lodestoneCharacter.Name, character.Name) || !strings.EqualFold(lodestoneCharacter.Server, character.Server) {
+		return ErrLodestoneCharacterMismatch
+	}
+	if !strings.Contains(lodestoneCharacter.Bio, characterClaim.Token) {
+		return ErrLodestoneTokenNotFound
+	}
+	return nil
+}
+
+// fetchCharacterForOwner fetches the character with the given uid and checks the request owns it.
+func fetchCharacterForOwner(r *http.Request, db *DatabaseHandler, uid string) (Character, error) {
+	character, err := db.FetchCharacterFromUID(uid)
+	if err != nil {
+		return character, err
+	}
+	verifiedClaim, err := db.FetchVerifiedCharacterClaim(character.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return character, ErrCharacterNotOwned
+		}
+		return character, err
+	}
+	if ownerKeyHash := readOwnerKeyHash(r); ownerKeyHash == "" || ownerKeyHash != verifiedClaim.OwnerKeyHash {
+		return character, ErrCharacterNotOwned
+	}
+	return character, nil
+}
+
+func registerClaimHandlers(mux *http.ServeMux, m *minify.M, db *DatabaseHandler, lodestone LodestoneFetcher) {
+
+	mux.Handle("/claim/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		td := getBaseTemplateData()
+		uid := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/claim/")))
+		if uid == "" {
+			displayError(w, "character id is required", 400)
+			return
+		}
+		character, err := db.FetchCharacterFromUID(uid)
+		if err == nil && character.IsMerged() {
+			err = gorm.ErrRecordNotFound
+		}
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				displayError(w, err.Error(), 404)
+				return
+			}
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Characters = []Character{character}
+		ownerKeyHash := ensureOwnerKeyHash(w, r)
+		characterClaim, err := db.FetchCharacterClaim(character.ID, ownerKeyHash)
+		if err == gorm.ErrRecordNotFound {
+			characterClaim = CharacterClaim{
+				CharacterID:  character.ID,
+				OwnerKeyHash: ownerKeyHash,
+				Token:        claimTokenPrefix + randomHex(6),
+			}
+			err = db.SaveCharacterClaim(&characterClaim)
+		}
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		if characterClaim.IsVerified() {
+			http.Redirect(w, r, "/c/"+character.UID, http.StatusSeeOther)
+			return
+		}
+		if r.Method == http.MethodPost {
+			lodestoneID := LodestoneIDFromURL(r.FormValue("lodestone"))
+			if lodestoneID == 0 {
+				td.Message = "Please enter a valid Lodestone character URL."
+			} else {
+				ctx, cancel := context.WithTimeout(r.Context(), lodestoneTimeout)
+				err = verifyCharacterClaim(ctx, lodestone, character, characterClaim, lodestoneID)
+				cancel()
+				if err == nil {
+					if err := db.VerifyCharacterClaim(&characterClaim, lodestoneID); err != nil {
+						displayError(w, err.Error(), 500)
+						return
+					}
+					http.Redirect(w, r, "/c/"+character.UID, http.StatusSeeOther)
+					return
+				}
+				td.Message = "Verification failed: " + err.Error() + "."
+			}
+		}
+		td.Owner, err = fetchCharacterOwnerData(r, db, character)
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		td.Owner.Claim = characterClaim
+		htmlTemplates["claim.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})))
+
+	mux.Handle("/owner/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			displayError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/owner/")))
+		character, err := fetchCharacterForOwner(r, db, uid)
+		if err != nil {
+			switch err {
+			case gorm.ErrRecordNotFound:
+				displayError(w, err.Error(), 404)
+			case ErrCharacterNotOwned:
+				displayError(w, err.Error(), 403)
+			default:
+				displayError(w, err.Error(), 500)
+			}
+			return
+		}
+		switch r.FormValue("action") {
+		case "hide", "show":
+			encounterID, err := strconv.Atoi(r.FormValue("encounter"))
+			if err != nil {
+				displayError(w, "encounter id is invalid", 400)
+				return
+			}
+			if _, err := db.FetchEncounterInfo(uint(encounterID)); err != nil {
+				displayError(w, err.Error(), 404)
+				return
+			}
+			err = db.SetCharacterEncounterHidden(character.ID, uint(encounterID), r.FormValue("action") == "hide")
+		case "job":
+			job := NormalizeJob(r.FormValue("job"))
+			if job != "" {
+				jobs, err := db.FetchCharacterJobs(character.ID)
+				if err != nil {
+					displayError(w, err.Error(), 500)
+					return
+				}
+				if !sliceContains(jobs, job) {
+					displayError(w, "job has no progression on this character", 400)
+					return
+				}
+			}
+			err = db.SetCharacterDisplayJob(character.ID, job)
+		case "alt":
+			// the main must also be verified by the same owner
+			main, err := fetchCharacterForOwner(r, db, strings.ToLower(strings.TrimSpace(r.FormValue("main"))))
+			if err != nil {
+				displayError(w, "main character: "+err.Error(), 400)
+				return
+			}
+			alts, err := db.FetchCharacterAlts(character.ID)
+			if err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+			if main.ID == character.ID || main.AltOfID != nil || len(alts) > 0 {
+				displayError(w, "alts can only be linked to a main character that is not itself an alt", 400)
+				return
+			}
+			err = db.SetCharacterAltOf(character.ID, &main.ID)
+			if err != nil {
+				displayError(w, err.Error(), 500)
+				return
+			}
+		case "unalt":
+			err = db.SetCharacterAltOf(character.ID, nil)
+		case "hide_character", "show_character":
+			err = db.SetCharacterHidden(character, r.FormValue("action") == "hide_character")
+		default:
+			displayError(w, "unknown action", 400)
+			return
+		}
+		if err != nil {
+			displayError(w, err.Error(), 500)
+			return
+		}
+		http.Redirect(w, r, "/c/"+character.UID, http.StatusSeeOther)
+	}))
+
+}
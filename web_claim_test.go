@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestLodestoneFixture writes lodestone profiles to a temporary fixture file and returns a fetcher that reads it.
+func writeTestLodestoneFixture(t *testing.T, characters map[string]LodestoneCharacter) LodestoneFetcher {
+	t.Helper()
+	rawData, err := json.Marshal(characters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "lodestone.json")
+	if err := os.WriteFile(path, rawData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewLodestoneFetcher(&Config{LodestoneFixtureFile: path})
+}
+
+func TestClaimImportedCharacter(t *testing.T) {
+	db, fflogHandler := newTestHandlers(t, newTestConfig(t))
+	importTestReport(t, db, fflogHandler, "FakeKillReport11")
+	character, err := db.FetchCharacterFromNameServer("Aria Vale", "Gilgamesh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerKey := "test-owner-key"
+	characterClaim := CharacterClaim{CharacterID: character.ID, OwnerKeyHash: hashOwnerKey(ownerKey), Token: claimTokenPrefix + randomHex(6)}
+	if err := db.SaveCharacterClaim(&characterClaim); err != nil {
+		t.Fatal(err)
+	}
+	// lodestone ids are unrelated to the game ids fflogs reports
+	lodestone := writeTestLodestoneFixture(t, map[string]LodestoneCharacter{
+		"31850291": {Name: "Aria Vale", Server: "Gilgamesh", Bio: "Static recruiting. " + characterClaim.Token},
+		"31850292": {Name: "Aria Vale", Server: "Gilgamesh", Bio: "No token here."},
+		"31850293": {Name: "Aria Vale", Server: "Cactuar", Bio: characterClaim.Token},
+		"29043117": {Name: "Bram Tolliver", Server: "Gilgamesh", Bio: characterClaim.Token},
+	})
+	for _, tt := range []struct {
+		LodestoneID int64
+		Err         error
+	}{
+		{31850291, nil},
+		{31850292, ErrLodestoneTokenNotFound},
+		{31850293, ErrLodestoneCharacterMismatch},
+		{29043117, ErrLodestoneCharacterMismatch},
+		{12345, ErrLodestoneCharacterNotFound},
+	} {
+		if err := verifyCharacterClaim(context.Background(), lodestone, character, characterClaim, tt.LodestoneID); err != tt.Err {
+			t.Errorf("lodestone %d: err = %v, want %v", tt.LodestoneID, err, tt.Err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	registerClaimHandlers(mux, newMinifier(), db, lodestone)
+	form := url.Values{"lodestone": {"https://na.finalfantasyxiv.com/lodestone/character/31850291/"}}
+	req := httptest.NewRequest(http.MethodPost, "/claim/"+character.UID, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: ownerCookieName, Value: ownerKey})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/c/"+character.UID {
+		t.Fatalf("claim responded %d %s, want redirect to the character", rec.Code, rec.Header().Get("Location"))
+	}
+	verifiedClaim, err := db.FetchVerifiedCharacterClaim(character.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifiedClaim.ID != characterClaim.ID || verifiedClaim.LodestoneID != 31850291 {
+		t.Errorf("verified claim %d with lodestone id %d, want claim %d with lodestone id 31850291", verifiedClaim.ID, verifiedClaim.LodestoneID, characterClaim.ID)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDisplayImportStatus(t *testing.T) {
+	for _, status := range []string{ImportStatusQueued, ImportStatusFetching, ImportStatusWriting, ImportStatusRetrying, ImportStatusDone, ImportStatusFailed, ImportStatusRemoved} {
+		rec := httptest.NewRecorder()
+		displayImportStatus(rec, ImportJob{ReportID: "FakeKillReport11", Status: status}, "")
+		// every status explains what happened to the report
+		if !strings.Contains(rec.Body.String(), "FakeKillReport11") {
+			t.Errorf("%s status does not describe the report: %q", status, rec.Body.String())
+		}
+	}
+}